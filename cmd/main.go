@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"we-connect-test/internal/di"
+	"we-connect-test/internal/financial"
 	"we-connect-test/internal/handler/api"
 	"we-connect-test/internal/queue"
 
@@ -26,22 +27,64 @@ func main() {
 		log.Fatal("cannot initialize mongo")
 	}
 	financialService := container.GetFinancialService()
-	//here we run queue
 	ctx := context.Background()
+	//convert documents stored before the fields were typed
+	err = financialService.MigrateFinancialData(ctx)
+	if err != nil {
+		logger.Fatal("cannot migrate financial data", zap.Error(err))
+	}
+	err = financialService.EnsureIndexes(ctx)
+	if err != nil {
+		logger.Fatal("cannot create financial data indexes", zap.Error(err))
+	}
+	queueService := container.GetQueueService()
+	err = queueService.EnsureIndexes(ctx)
+	if err != nil {
+		logger.Fatal("cannot create queue indexes", zap.Error(err))
+	}
+	err = queueService.MarkInterruptedImports(ctx)
+	if err != nil {
+		logger.Fatal("cannot mark interrupted imports", zap.Error(err))
+	}
+	//here we run queue
 	go func() {
-		queueManager := queue.NewManager(financialService, logger)
+		queueManager := queue.NewManager(container.GetCfg(), financialService, container.GetQueueRepository(), logger)
 		filePath := "./data.csv"
 		workerCount := 5
-		err = queueManager.Run(ctx, filePath, workerCount)
+		summary, err := queueManager.Run(ctx, filePath, workerCount)
 		if err != nil {
 			logger.Fatal("err in queueManager", zap.Error(err))
 		}
+		logger.Info("queueManager finished",
+			zap.Int64("inserted", summary.Inserted),
+			zap.Int("failed", len(summary.FailedLines)),
+			zap.Int64("skipped", summary.Skipped),
+			zap.Duration("duration", summary.Duration),
+		)
 	}()
 
+	//here we import files copied into the drop folder
+	watcher := queue.NewWatcher(container.GetCfg(), financialService, container.GetQueueRepository(), logger)
+	if watcher != nil {
+		go func() {
+			err := watcher.Start(ctx)
+			if err != nil {
+				logger.Fatal("err in queue watcher", zap.Error(err))
+			}
+		}()
+	}
+
+	//here we purge the financial data deleted long enough ago
+	purger := financial.NewPurger(container.GetCfg(), financialService, logger)
+	if purger != nil {
+		go purger.Start(ctx)
+	}
+
 	//here we run httpServer
 	httpServer := api.NewHttpServer(api.Services{
 		Cfg:              container.GetCfg(),
 		FinancialService: financialService,
+		QueueService:     queueService,
 	}, logger)
 	err = httpServer.ListenAndServe(fmt.Sprintf("0.0.0.0:%s", defaultPort))
 	if err != nil {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -30,6 +31,18 @@ func (c *Cfg) GetBool(name string) bool {
 	return c.viper.GetBool(name)
 }
 
+func (c *Cfg) GetDuration(name string) time.Duration {
+	return c.viper.GetDuration(name)
+}
+
+func (c *Cfg) GetStringMap(name string) map[string]interface{} {
+	return c.viper.GetStringMap(name)
+}
+
+func (c *Cfg) GetStringMapString(name string) map[string]string {
+	return c.viper.GetStringMapString(name)
+}
+
 func (c *Cfg) GetEnv() string {
 	return c.GetString(EnvConfigKey)
 
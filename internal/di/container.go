@@ -6,6 +6,7 @@ import (
 	"we-connect-test/internal/financial"
 	"we-connect-test/internal/handler/api"
 	"we-connect-test/internal/logger"
+	"we-connect-test/internal/queue"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
@@ -17,6 +18,8 @@ type Container struct {
 	cfg              *config.Cfg
 	financialService *financial.Service
 	financialRepo    *financial.Repository
+	queueRepo        *queue.Repository
+	queueService     *queue.Service
 	mongoDBClient    *mongo.Client
 }
 
@@ -71,6 +74,26 @@ func (c *Container) GetFinancialService() *financial.Service {
 	return c.financialService
 }
 
+func (c *Container) GetQueueRepository() *queue.Repository {
+	if c.queueRepo == nil {
+		cfg := c.GetCfg()
+		mongoDBClient, _ := c.GetMongoDBClient()
+		c.queueRepo = queue.NewRepository(cfg, mongoDBClient)
+	}
+	return c.queueRepo
+}
+
+func (c *Container) GetQueueService() *queue.Service {
+	if c.queueService == nil {
+		cfg := c.GetCfg()
+		repo := c.GetQueueRepository()
+		financialService := c.GetFinancialService()
+		logger, _ := c.GetLogger()
+		c.queueService = queue.NewService(cfg, repo, financialService, logger)
+	}
+	return c.queueService
+}
+
 func NewContainer() *Container {
 	return &Container{}
 }
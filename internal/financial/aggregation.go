@@ -0,0 +1,104 @@
+package financial
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"we-connect-test/internal/response"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.uber.org/zap"
+)
+
+// aggregationDimensions maps the groupBy names of the api to the expression
+// grouped on.
+var aggregationDimensions = map[string]string{
+	"seriesReference": "$seriesReference",
+	"status":          "$status",
+	"units":           "$units",
+	"subject":         "$subject",
+	"group":           "$group",
+	"seriesTitle1":    "$seriesTitle1",
+	"seriesTitle2":    "$seriesTitle2",
+	"seriesTitle3":    "$seriesTitle3",
+	"seriesTitle4":    "$seriesTitle4",
+	"seriesTitle5":    "$seriesTitle5",
+	"year":            "$period.year",
+}
+
+type AggregateFinancialDataParams struct {
+	GroupBy string `form:"groupBy"`
+	FinancialFilterParams
+}
+
+type SingleAggregationResult struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Sum   string `json:"sum"`
+	Avg   string `json:"avg"`
+	Min   string `json:"min"`
+	Max   string `json:"max"`
+}
+
+// AggregateFinancialData returns the count, sum, average, min and max of the
+// data values grouped by params.GroupBy. Values are summed as they are
+// stored, so groups mixing units or magnitudes are best narrowed by filters.
+func (s *Service) AggregateFinancialData(
+	ctx context.Context,
+	params AggregateFinancialDataParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	dimension, ok := aggregationDimensions[params.GroupBy]
+	if !ok {
+		names := make([]string, 0, len(aggregationDimensions))
+		for name := range aggregationDimensions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		message := fmt.Sprintf("groupBy must be one of %s", strings.Join(names, ", "))
+		return response.Error(message, http.StatusBadRequest, nil)
+	}
+	filter, err := params.toFinancialFilter()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	models, err := s.repo.AggregateFinancialData(ctx, filter, dimension)
+	if err != nil {
+		s.logger.Error("cannot AggregateFinancialData",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "AggregateFinancialData"),
+			zap.String("groupBy", params.GroupBy),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make([]SingleAggregationResult, len(models))
+	for i, m := range models {
+		res[i] = SingleAggregationResult{
+			Key:   aggregationKey(m.Key),
+			Count: m.Count,
+			Sum:   formatDataValue(m.Sum),
+			Avg:   formatDataValue(m.Avg),
+			Min:   formatDataValue(m.Min),
+			Max:   formatDataValue(m.Max),
+		}
+	}
+	return response.Success(res, "")
+}
+
+// aggregationKey formats a group key, which is a string or the year number.
+func aggregationKey(v bson.RawValue) string {
+	switch v.Type {
+	case bsontype.String:
+		return v.StringValue()
+	case bsontype.Int32:
+		return fmt.Sprint(v.Int32())
+	case bsontype.Int64:
+		return fmt.Sprint(v.Int64())
+	case bsontype.Null, bsontype.Undefined, 0:
+		return ""
+	}
+	return v.String()
+}
@@ -0,0 +1,148 @@
+package financial
+
+import (
+	"context"
+	"net/http"
+	"we-connect-test/internal/response"
+)
+
+// MaxBulkOperations is the largest number of operations one bulk request
+// may hold.
+const MaxBulkOperations = 1000
+
+const errSkippedOperation = "skipped after an earlier operation failed"
+
+// BulkFinancialDataParams runs Operations in order. Ordered, true when not
+// set, stops at the first failed operation and skips the ones after it,
+// otherwise every operation is run whatever the others answered.
+type BulkFinancialDataParams struct {
+	Ordered    *bool                 `json:"ordered"`
+	Operations []BulkOperationParams `json:"operations" binding:"required,min=1,max=1000,dive"`
+}
+
+// BulkOperationParams holds exactly one of Create, Update or Delete, each
+// validated like the request of the single record route. IfMatch is the
+// If-Match of an update or delete, the revision of the ETag of the record.
+type BulkOperationParams struct {
+	Create  *CreateFinancialDataParams `json:"create"`
+	Update  *UpdateFinancialDataParams `json:"update"`
+	Delete  *DeleteFinancialDataParams `json:"delete"`
+	IfMatch *int64                     `json:"ifMatch"`
+}
+
+// BulkOperationResult is the outcome of the operation at Index. Status is
+// the status code the operation answers with on its own route, or 424 when
+// it was skipped. Revision is the one an update wrote.
+type BulkOperationResult struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Status   int    `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Revision int64  `json:"revision,omitempty"`
+}
+
+type BulkMeta struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// BulkFinancialData runs the create, update and delete operations of params
+// one by one and returns a result per operation, in the order they were
+// given. The request succeeds even when operations fail, the failures are in
+// the results.
+func (s *Service) BulkFinancialData(
+	ctx context.Context,
+	params BulkFinancialDataParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if len(params.Operations) == 0 {
+		return response.Error("no operations", http.StatusBadRequest, nil)
+	}
+	if len(params.Operations) > MaxBulkOperations {
+		return response.Error("too many operations", http.StatusBadRequest, nil)
+	}
+	ordered := params.Ordered == nil || *params.Ordered
+	results := make([]BulkOperationResult, len(params.Operations))
+	meta := BulkMeta{}
+	failed := false
+	for i, op := range params.Operations {
+		if failed && ordered {
+			results[i] = BulkOperationResult{
+				Index:  i,
+				ID:     op.id(),
+				Status: http.StatusFailedDependency,
+				Error:  errSkippedOperation,
+			}
+			meta.Skipped++
+			continue
+		}
+		results[i] = s.runBulkOperation(ctx, i, op)
+		if results[i].Status >= http.StatusBadRequest {
+			failed = true
+			meta.Failed++
+			continue
+		}
+		meta.Succeeded++
+	}
+	return response.SuccessWithMeta(results, meta, "")
+}
+
+func (s *Service) runBulkOperation(ctx context.Context, index int, op BulkOperationParams) BulkOperationResult {
+	result := BulkOperationResult{Index: index, ID: op.id()}
+	var resp response.ApiResponse
+	switch {
+	case op.count() != 1:
+		resp, result.Status = response.Error("exactly one of create, update or delete must be set", http.StatusBadRequest, nil)
+	case op.Create != nil && op.IfMatch != nil:
+		resp, result.Status = response.Error("ifMatch only applies to update and delete", http.StatusBadRequest, nil)
+	case op.Create != nil:
+		resp, result.Status = s.CreateFinancialDataByUser(ctx, *op.Create)
+		if created, ok := resp.Data.(CreateFinancialDataResult); ok {
+			result.ID = created.ID
+		}
+	case op.Update != nil:
+		params := *op.Update
+		params.IfRevisions = op.ifRevisions()
+		resp, result.Status = s.UpdateFinancialData(ctx, params)
+		if updated, ok := resp.Data.(SingleFinancialDataResult); ok {
+			result.Revision = updated.Revision
+		}
+	case op.Delete != nil:
+		params := *op.Delete
+		params.IfRevisions = op.ifRevisions()
+		resp, result.Status = s.DeleteFinancialData(ctx, params)
+	}
+	if !resp.Status {
+		result.Error = resp.Message
+	}
+	return result
+}
+
+// count is the number of operations op holds.
+func (op BulkOperationParams) count() int {
+	n := 0
+	for _, set := range []bool{op.Create != nil, op.Update != nil, op.Delete != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+func (op BulkOperationParams) ifRevisions() []int64 {
+	if op.IfMatch == nil {
+		return nil
+	}
+	return []int64{*op.IfMatch}
+}
+
+// id is the id of the record op updates or deletes, empty for a create.
+func (op BulkOperationParams) id() string {
+	switch {
+	case op.Update != nil:
+		return op.Update.ID
+	case op.Delete != nil:
+		return op.Delete.ID
+	}
+	return ""
+}
@@ -0,0 +1,210 @@
+package financial
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"we-connect-test/internal/response"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// Statuses of a PeriodChange. A change is only computed against the
+// observation exactly one quarter or one year earlier, never against the
+// closest one available.
+const (
+	ChangeStatusOK = "ok"
+	// ChangeStatusNoPriorPeriod means the series has no observation for the
+	// prior period.
+	ChangeStatusNoPriorPeriod = "noPriorPeriod"
+	// ChangeStatusSuppressed means the observation or its prior one has no
+	// data value.
+	ChangeStatusSuppressed = "suppressed"
+	// ChangeStatusZeroBase means the prior value is zero, the change is set
+	// but the percentage is undefined.
+	ChangeStatusZeroBase = "zeroBase"
+)
+
+// percentChangeDecimals is the number of decimals percentages are rounded to.
+const percentChangeDecimals = 2
+
+type GetSeriesChangesParams struct {
+	Page     int `form:"page"`
+	PageSize int `form:"pageSize"`
+	// SeriesReference is set from the path for the changes of one series.
+	SeriesReference string `form:"-"`
+	FinancialFilterParams
+}
+
+type PeriodChange struct {
+	PriorPeriod   string `json:"priorPeriod"`
+	PriorValue    string `json:"priorValue"`
+	Change        string `json:"change"`
+	PercentChange string `json:"percentChange"`
+	Status        string `json:"status"`
+}
+
+type SingleChangeResult struct {
+	SeriesReference  string       `json:"seriesReference"`
+	Period           string       `json:"period"`
+	DataValue        string       `json:"dataValue"`
+	QuarterOnQuarter PeriodChange `json:"quarterOnQuarter"`
+	YearOnYear       PeriodChange `json:"yearOnYear"`
+}
+
+// GetSeriesChanges returns the quarter on quarter and year on year changes
+// of the observations of a page of series. Filters select the series like in
+// GetSeriesList, periodFrom and periodTo the observations returned. Prior
+// periods are read even when they are before periodFrom.
+func (s *Service) GetSeriesChanges(
+	ctx context.Context,
+	params GetSeriesChangesParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if params.Page < 0 {
+		params.Page = 0
+	}
+	if params.PageSize < 2 {
+		params.PageSize = 2
+	}
+	if params.PageSize > 100 {
+		params.PageSize = 100
+	}
+	if params.SeriesReference != "" {
+		params.FinancialFilterParams.SeriesReference = params.SeriesReference
+		params.Page = 0
+	}
+	filter, err := params.toFinancialFilter()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	series, err := s.repo.GetSeriesList(ctx, filter, params.Page*params.PageSize, params.PageSize)
+	if err != nil {
+		s.logger.Error("cannot GetSeriesList",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetSeriesChanges"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	if len(series) == 0 {
+		if params.SeriesReference != "" {
+			return response.Error("not found", http.StatusNotFound, nil)
+		}
+		return response.Success([]SingleChangeResult{}, "")
+	}
+	observationFilter := FinancialFilter{
+		SeriesReferences: make([]string, len(series)),
+		PeriodTo:         filter.PeriodTo,
+	}
+	for i, m := range series {
+		observationFilter.SeriesReferences[i] = m.SeriesReference
+	}
+	if filter.PeriodFrom != nil {
+		from := filter.PeriodFrom.AddQuarters(-4)
+		observationFilter.PeriodFrom = &from
+	}
+	models, err := s.repo.GetObservations(ctx, observationFilter)
+	if err != nil {
+		s.logger.Error("cannot GetObservations",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetSeriesChanges"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make([]SingleChangeResult, 0, len(models))
+	for _, c := range periodChanges(models) {
+		if filter.PeriodFrom != nil && c.period.Before(*filter.PeriodFrom) {
+			continue
+		}
+		res = append(res, c.result)
+	}
+	return response.Success(res, "")
+}
+
+type periodChangeResult struct {
+	period Period
+	result SingleChangeResult
+}
+
+// periodChanges computes the changes of models, which are ordered by
+// seriesReference and period.
+func periodChanges(models []FinancialModel) []periodChangeResult {
+	res := make([]periodChangeResult, 0, len(models))
+	values := map[Period]*primitive.Decimal128{}
+	for i, m := range models {
+		if i == 0 || models[i-1].SeriesReference != m.SeriesReference {
+			values = map[Period]*primitive.Decimal128{}
+		}
+		values[m.Period] = m.DataValue
+		res = append(res, periodChangeResult{
+			period: m.Period,
+			result: SingleChangeResult{
+				SeriesReference:  m.SeriesReference,
+				Period:           m.Period.String(),
+				DataValue:        formatDataValue(m.DataValue),
+				QuarterOnQuarter: newPeriodChange(m, m.Period.AddQuarters(-1), values),
+				YearOnYear:       newPeriodChange(m, m.Period.AddQuarters(-4), values),
+			},
+		})
+	}
+	return res
+}
+
+func newPeriodChange(m FinancialModel, prior Period, values map[Period]*primitive.Decimal128) PeriodChange {
+	c := PeriodChange{PriorPeriod: prior.String()}
+	priorValue, ok := values[prior]
+	if !ok {
+		c.Status = ChangeStatusNoPriorPeriod
+		return c
+	}
+	c.PriorValue = formatDataValue(priorValue)
+	if m.DataValue == nil || priorValue == nil {
+		c.Status = ChangeStatusSuppressed
+		return c
+	}
+	current, currentDecimals, err := decimalToRat(*m.DataValue)
+	if err != nil {
+		c.Status = ChangeStatusSuppressed
+		return c
+	}
+	previous, previousDecimals, err := decimalToRat(*priorValue)
+	if err != nil {
+		c.Status = ChangeStatusSuppressed
+		return c
+	}
+	change := new(big.Rat).Sub(current, previous)
+	decimals := currentDecimals
+	if previousDecimals > decimals {
+		decimals = previousDecimals
+	}
+	c.Change = change.FloatString(decimals)
+	if previous.Sign() == 0 {
+		c.Status = ChangeStatusZeroBase
+		return c
+	}
+	//relative to the magnitude of the prior value, so a rise from a negative
+	//value is a positive percentage
+	percent := new(big.Rat).Quo(change, new(big.Rat).Abs(previous))
+	percent.Mul(percent, big.NewRat(100, 1))
+	c.PercentChange = percent.FloatString(percentChangeDecimals)
+	c.Status = ChangeStatusOK
+	return c
+}
+
+// decimalToRat returns d as an exact rational and the number of decimals it
+// is written with.
+func decimalToRat(d primitive.Decimal128) (*big.Rat, int, error) {
+	coefficient, exp, err := d.BigInt()
+	if err != nil {
+		return nil, 0, err
+	}
+	r := new(big.Rat).SetInt(coefficient)
+	if exp < 0 {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exp)), nil)
+		return r.Quo(r, new(big.Rat).SetInt(scale)), -exp, nil
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+	return r.Mul(r, new(big.Rat).SetInt(scale)), 0, nil
+}
@@ -0,0 +1,180 @@
+package financial
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// FinancialSort orders a list on one field. Models with the same value are
+// ordered by _id in the same direction, so the order is total and a cursor
+// always points to one position.
+type FinancialSort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a sort parameter such as period or -dataValue. An empty
+// sort orders by id.
+func ParseSort(s string) (FinancialSort, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return FinancialSort{Field: "_id"}, nil
+	}
+	sort := FinancialSort{}
+	if strings.HasPrefix(s, "-") {
+		sort.Desc = true
+		s = s[1:]
+	}
+	field, ok := apiFields[strings.TrimPrefix(s, "+")]
+	if !ok {
+		return FinancialSort{}, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, s)
+	}
+	sort.Field = field
+	return sort, nil
+}
+
+func (s FinancialSort) toBSON(backward bool) bson.D {
+	direction := 1
+	if s.Desc != backward {
+		direction = -1
+	}
+	if s.Field == "_id" {
+		return bson.D{{Key: "_id", Value: direction}}
+	}
+	return bson.D{{Key: s.Field, Value: direction}, {Key: "_id", Value: direction}}
+}
+
+// listCursor is the position of a model in a sorted list. Backward cursors
+// page towards the start of the list.
+type listCursor struct {
+	Field    string             `bson:"f"`
+	Desc     bool               `bson:"d"`
+	Value    bson.RawValue      `bson:"v"`
+	ID       primitive.ObjectID `bson:"i"`
+	Backward bool               `bson:"b"`
+}
+
+func newListCursor(m FinancialModel, sort FinancialSort, backward bool) (listCursor, error) {
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return listCursor{}, err
+	}
+	value, err := bson.Raw(raw).LookupErr(sort.Field)
+	if err != nil {
+		value = bson.RawValue{Type: bsontype.Null}
+	}
+	return listCursor{
+		Field:    sort.Field,
+		Desc:     sort.Desc,
+		Value:    value,
+		ID:       m.ID,
+		Backward: backward,
+	}, nil
+}
+
+func (c listCursor) sort() FinancialSort {
+	return FinancialSort{Field: c.Field, Desc: c.Desc}
+}
+
+// encode returns the cursor as an opaque url safe string.
+func (c listCursor) encode() (string, error) {
+	raw, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return listCursor{}, ErrInvalidCursor
+	}
+	c := listCursor{}
+	err = bson.Unmarshal(raw, &c)
+	if err != nil || c.ID.IsZero() || !isSortField(c.Field) || !validCursorValue(c.Field, c.Value) {
+		return listCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// isSortField reports whether field is a stored field a list can be sorted
+// on. Cursors come from clients, so their field is checked like a sort.
+func isSortField(field string) bool {
+	for _, f := range apiFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// validCursorValue reports whether v can be a value of field. The value ends
+// up in the filter of the list, where a document such as {"$ne": null}
+// would be read as an operator, so only periods may be documents and only
+// with their own keys.
+func validCursorValue(field string, v bson.RawValue) bool {
+	switch v.Type {
+	case bsontype.Null, bsontype.String, bsontype.Int32, bsontype.Int64, bsontype.Double,
+		bsontype.Decimal128, bsontype.ObjectID:
+		return true
+	case bsontype.EmbeddedDocument:
+		if field != "period" {
+			return false
+		}
+		elements, err := v.Document().Elements()
+		if err != nil || len(elements) != 2 {
+			return false
+		}
+		for _, e := range elements {
+			if e.Key() != "year" && e.Key() != "quarter" {
+				return false
+			}
+			if t := e.Value().Type; t != bsontype.Int32 && t != bsontype.Int64 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// filter matches the models after the cursor in the direction it pages to.
+// Comparisons in mongo never match null against a value, and null sorts
+// before every value, so models without the field are matched separately.
+func (c listCursor) filter() bson.M {
+	ascending := c.Desc == c.Backward
+	op := "$gt"
+	if !ascending {
+		op = "$lt"
+	}
+	if c.Field == "_id" {
+		return bson.M{"_id": bson.M{op: c.ID}}
+	}
+	if c.Value.Type == bsontype.Null {
+		sameValue := bson.M{c.Field: nil, "_id": bson.M{op: c.ID}}
+		if ascending {
+			return bson.M{"$or": bson.A{sameValue, bson.M{c.Field: bson.M{"$ne": nil}}}}
+		}
+		return sameValue
+	}
+	after := bson.A{
+		bson.M{c.Field: bson.M{op: c.Value}},
+		bson.M{c.Field: c.Value, "_id": bson.M{op: c.ID}},
+	}
+	if !ascending {
+		after = append(after, bson.M{c.Field: nil})
+	}
+	return bson.M{"$or": after}
+}
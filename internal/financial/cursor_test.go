@@ -0,0 +1,63 @@
+package financial_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"we-connect-test/internal/financial"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestParseSort(t *testing.T) {
+	s, err := financial.ParseSort("")
+	assert.Nil(t, err)
+	assert.Equal(t, s, financial.FinancialSort{Field: "_id"})
+
+	s, err = financial.ParseSort("period")
+	assert.Nil(t, err)
+	assert.Equal(t, s, financial.FinancialSort{Field: "period"})
+
+	s, err = financial.ParseSort("-dataValue")
+	assert.Nil(t, err)
+	assert.Equal(t, s, financial.FinancialSort{Field: "dataValue", Desc: true})
+
+	s, err = financial.ParseSort("-id")
+	assert.Nil(t, err)
+	assert.Equal(t, s, financial.FinancialSort{Field: "_id", Desc: true})
+
+	for _, invalid := range []string{"unknown", "-", "_id", "Period"} {
+		_, err = financial.ParseSort(invalid)
+		assert.ErrorIs(t, err, financial.ErrInvalidSort, invalid)
+	}
+}
+
+func TestForgedCursor(t *testing.T) {
+	//cursors are rejected before the repository is used
+	service := financial.NewService(nil, zap.NewNop())
+	forge := func(cursor bson.M) string {
+		cursor["i"] = primitive.NewObjectID()
+		raw, err := bson.Marshal(cursor)
+		assert.Nil(t, err)
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	for name, cursor := range map[string]bson.M{
+		"operator value":      {"f": "seriesReference", "v": bson.M{"$ne": nil}},
+		"array value":         {"f": "seriesReference", "v": bson.A{"a", "b"}},
+		"unknown field":       {"f": "secret", "v": "a"},
+		"operator field":      {"f": "$where", "v": "a"},
+		"period with ops":     {"f": "period", "v": bson.M{"$gt": 0}},
+		"period with extra":   {"f": "period", "v": bson.M{"year": 2016, "quarter": 2, "x": 1}},
+		"period with strings": {"f": "period", "v": bson.M{"year": "2016", "quarter": 2}},
+	} {
+		resp, statusCode := service.GetFinancialDataList(context.Background(), financial.GetFinancialDataListParams{
+			Cursor: forge(cursor),
+		})
+		assert.Equal(t, statusCode, http.StatusBadRequest, name)
+		assert.Equal(t, resp.Message, financial.ErrInvalidCursor.Error(), name)
+	}
+}
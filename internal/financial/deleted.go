@@ -0,0 +1,99 @@
+package financial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"we-connect-test/internal/response"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type GetDeletedFinancialDataListParams struct {
+	Page     int `form:"page"`
+	PageSize int `form:"pageSize"`
+}
+
+type RestoreFinancialDataParams struct {
+	ID string `json:"-"`
+}
+
+// GetDeletedFinancialDataList returns a page of the deleted records, the
+// latest deleted first, with their total in the meta.
+func (s *Service) GetDeletedFinancialDataList(
+	ctx context.Context,
+	params GetDeletedFinancialDataListParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if params.Page < 0 {
+		params.Page = 0
+	}
+	if params.PageSize < 2 {
+		params.PageSize = 2
+	}
+	if params.PageSize > 100 {
+		params.PageSize = 100
+	}
+	models, err := s.repo.GetDeletedFinancialDataList(ctx, params.Page*params.PageSize, params.PageSize)
+	if err != nil {
+		s.logger.Error("cannot GetDeletedFinancialDataList",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetDeletedFinancialDataList"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	total, err := s.repo.CountDeletedFinancialData(ctx)
+	if err != nil {
+		s.logger.Error("cannot CountDeletedFinancialData",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetDeletedFinancialDataList"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make([]SingleFinancialDataResult, len(models))
+	for i, m := range models {
+		res[i] = toSingleFinancialDataResult(m)
+	}
+	return response.SuccessWithMeta(res, ListMeta{Total: &total}, "")
+}
+
+// RestoreFinancialData undoes the delete of the record and returns it.
+func (s *Service) RestoreFinancialData(
+	ctx context.Context,
+	params RestoreFinancialDataParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if !primitive.IsValidObjectID(params.ID) {
+		return response.Error("invalid id", http.StatusBadRequest, nil)
+	}
+	m, err := s.repo.RestoreFinancialData(ctx, params.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return response.Error("not found", http.StatusNotFound, nil)
+	}
+	if err != nil {
+		s.logger.Error("cannot RestoreFinancialData",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "RestoreFinancialData"),
+			zap.String("id", params.ID),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	return response.Success(toSingleFinancialDataResult(m), "")
+}
+
+// PurgeDeletedFinancialData removes the records deleted at or before
+// deletedBefore for good, they cannot be restored anymore.
+func (s *Service) PurgeDeletedFinancialData(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return s.repo.PurgeFinancialData(ctx, deletedBefore)
+}
+
+func formatDeletedAt(deletedAt *time.Time) string {
+	if deletedAt == nil {
+		return ""
+	}
+	return deletedAt.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,49 @@
+package financial
+
+import (
+	"context"
+	"net/http"
+	"we-connect-test/internal/response"
+
+	"go.uber.org/zap"
+)
+
+type GetFinancialFacetsParams struct {
+	FinancialFilterParams
+}
+
+type SingleFacetResult struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// GetFinancialFacets returns the distinct values and their counts of the
+// facet fields within the list filters, keyed by field name. Every field is
+// present, with an empty list when no record has a value for it.
+func (s *Service) GetFinancialFacets(
+	ctx context.Context,
+	params GetFinancialFacetsParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	filter, err := params.toFinancialFilter()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	facets, err := s.repo.GetFinancialFacets(ctx, filter)
+	if err != nil {
+		s.logger.Error("cannot GetFinancialFacets",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetFinancialFacets"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make(map[string][]SingleFacetResult, len(facetFields))
+	for _, field := range facetFields {
+		values := make([]SingleFacetResult, len(facets[field]))
+		for i, v := range facets[field] {
+			values[i] = SingleFacetResult{Value: v.Value, Count: v.Count}
+		}
+		res[field] = values
+	}
+	return response.Success(res, "")
+}
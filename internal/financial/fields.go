@@ -0,0 +1,123 @@
+package financial
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrInvalidFields = errors.New("invalid fields")
+
+// apiFields maps the field names of the api to the stored ones.
+var apiFields = map[string]string{
+	"id":              "_id",
+	"seriesReference": "seriesReference",
+	"period":          "period",
+	"dataValue":       "dataValue",
+	"suppressed":      "suppressed",
+	"status":          "status",
+	"units":           "units",
+	"magnitude":       "magnitude",
+	"subject":         "subject",
+	"group":           "group",
+	"seriesTitle1":    "seriesTitle1",
+	"seriesTitle2":    "seriesTitle2",
+	"seriesTitle3":    "seriesTitle3",
+	"seriesTitle4":    "seriesTitle4",
+	"seriesTitle5":    "seriesTitle5",
+}
+
+// FinancialFields are the api names of the fields a list returns. Empty
+// fields return every field.
+type FinancialFields []string
+
+// ParseFields parses a comma separated list of field names such as
+// period,dataValue.
+func ParseFields(s string) (FinancialFields, error) {
+	var fields FinancialFields
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := apiFields[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidFields, name)
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// toBSON returns the projection of the fields, with the stored fields in
+// required added. It is nil when every field is returned.
+func (f FinancialFields) toBSON(required ...string) bson.M {
+	if len(f) == 0 {
+		return nil
+	}
+	projection := bson.M{}
+	for _, name := range f {
+		projection[apiFields[name]] = 1
+	}
+	for _, field := range required {
+		projection[field] = 1
+	}
+	return projection
+}
+
+// project returns r with only the fields, and the scaled value fields when
+// they are set.
+func (f FinancialFields) project(r SingleFinancialDataResult) map[string]string {
+	all := map[string]string{
+		"id":              r.ID,
+		"seriesReference": r.SeriesReference,
+		"period":          r.Period,
+		"dataValue":       r.DataValue,
+		"suppressed":      r.Suppressed,
+		"status":          r.Status,
+		"units":           r.Units,
+		"magnitude":       r.Magnitude,
+		"subject":         r.Subject,
+		"group":           r.Group,
+		"seriesTitle1":    r.SeriesTitle1,
+		"seriesTitle2":    r.SeriesTitle2,
+		"seriesTitle3":    r.SeriesTitle3,
+		"seriesTitle4":    r.SeriesTitle4,
+		"seriesTitle5":    r.SeriesTitle5,
+	}
+	projected := make(map[string]string, len(f)+3)
+	for _, name := range f {
+		projected[name] = all[name]
+	}
+	if r.ScaledValue != "" {
+		projected["scaledValue"] = r.ScaledValue
+		projected["scaledUnits"] = r.ScaledUnits
+		projected["formattedValue"] = r.FormattedValue
+	}
+	return projected
+}
+
+// withScaledValue adds the fields a scaled value is computed from, which
+// have to be read even when they are not returned.
+func (f FinancialFields) withScaledValue() FinancialFields {
+	if len(f) == 0 {
+		return f
+	}
+	return append(append(FinancialFields{}, f...), "dataValue", "magnitude", "units")
+}
+
+// results returns rs projected on the fields, or rs itself when every field
+// is returned.
+func (f FinancialFields) results(rs []SingleFinancialDataResult) interface{} {
+	if len(f) == 0 {
+		return rs
+	}
+	projected := make([]map[string]string, len(rs))
+	for i, r := range rs {
+		projected[i] = f.project(r)
+	}
+	return projected
+}
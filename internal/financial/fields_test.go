@@ -0,0 +1,23 @@
+package financial_test
+
+import (
+	"testing"
+	"we-connect-test/internal/financial"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFields(t *testing.T) {
+	f, err := financial.ParseFields("")
+	assert.Nil(t, err)
+	assert.Empty(t, f)
+
+	f, err = financial.ParseFields("period, dataValue,period,")
+	assert.Nil(t, err)
+	assert.Equal(t, f, financial.FinancialFields{"period", "dataValue"})
+
+	for _, invalid := range []string{"unknown", "period,_id", "Period", "scaledValue"} {
+		_, err = financial.ParseFields(invalid)
+		assert.ErrorIs(t, err, financial.ErrInvalidFields, invalid)
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 	"we-connect-test/internal/di"
 	"we-connect-test/internal/financial"
 	"we-connect-test/internal/handler/api"
+	"we-connect-test/internal/response"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
@@ -43,12 +47,12 @@ func TestIndex(t *testing.T) {
 	//first we insert 4 docs to db
 	id1, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
 		SeriesReference: "sr1",
-		Period:          "period1",
-		DataValue:       "dataValue1",
+		Period:          financial.Period{Year: 2016, Quarter: 2},
+		DataValue:       decimal(t, "1116.386"),
 		Suppressed:      "suppressed1",
 		Status:          "status1",
 		Units:           "units1",
-		Magnitude:       "magnitude1",
+		Magnitude:       1,
 		Subject:         "subject1",
 		Group:           "group1",
 		SeriesTitle1:    "seriesTitle11",
@@ -60,12 +64,12 @@ func TestIndex(t *testing.T) {
 	assert.Nil(t, err)
 	id2, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
 		SeriesReference: "sr2",
-		Period:          "period2",
-		DataValue:       "dataValue2",
+		Period:          financial.Period{Year: 2016, Quarter: 3},
+		DataValue:       decimal(t, "1070.874"),
 		Suppressed:      "suppressed2",
 		Status:          "status2",
 		Units:           "units2",
-		Magnitude:       "magnitude2",
+		Magnitude:       2,
 		Subject:         "subject2",
 		Group:           "group2",
 		SeriesTitle1:    "seriesTitle12",
@@ -77,12 +81,12 @@ func TestIndex(t *testing.T) {
 	assert.Nil(t, err)
 	id3, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
 		SeriesReference: "sr3",
-		Period:          "period3",
-		DataValue:       "dataValue3",
+		Period:          financial.Period{Year: 2016, Quarter: 4},
+		DataValue:       decimal(t, "1054.408"),
 		Suppressed:      "suppressed3",
 		Status:          "status3",
 		Units:           "units3",
-		Magnitude:       "magnitude3",
+		Magnitude:       3,
 		Subject:         "subject3",
 		Group:           "group3",
 		SeriesTitle1:    "seriesTitle13",
@@ -94,12 +98,12 @@ func TestIndex(t *testing.T) {
 	assert.Nil(t, err)
 	id4, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
 		SeriesReference: "sr4",
-		Period:          "period4",
-		DataValue:       "dataValue4",
+		Period:          financial.Period{Year: 2017, Quarter: 1},
+		DataValue:       decimal(t, "1010.665"),
 		Suppressed:      "suppressed4",
 		Status:          "status4",
 		Units:           "units4",
-		Magnitude:       "magnitude4",
+		Magnitude:       4,
 		Subject:         "subject4",
 		Group:           "group4",
 		SeriesTitle1:    "seriesTitle14",
@@ -131,12 +135,12 @@ func TestIndex(t *testing.T) {
 	fd1 := result.Data[0]
 	assert.Equal(t, fd1.ID, id1)
 	assert.Equal(t, fd1.SeriesReference, "sr1")
-	assert.Equal(t, fd1.Period, "period1")
-	assert.Equal(t, fd1.DataValue, "dataValue1")
+	assert.Equal(t, fd1.Period, "2016.06")
+	assert.Equal(t, fd1.DataValue, "1116.386")
 	assert.Equal(t, fd1.Suppressed, "suppressed1")
 	assert.Equal(t, fd1.Status, "status1")
 	assert.Equal(t, fd1.Units, "units1")
-	assert.Equal(t, fd1.Magnitude, "magnitude1")
+	assert.Equal(t, fd1.Magnitude, "1")
 	assert.Equal(t, fd1.Subject, "subject1")
 	assert.Equal(t, fd1.Group, "group1")
 	assert.Equal(t, fd1.SeriesTitle1, "seriesTitle11")
@@ -147,12 +151,12 @@ func TestIndex(t *testing.T) {
 	fd2 := result.Data[1]
 	assert.Equal(t, fd2.ID, id2)
 	assert.Equal(t, fd2.SeriesReference, "sr2")
-	assert.Equal(t, fd2.Period, "period2")
-	assert.Equal(t, fd2.DataValue, "dataValue2")
+	assert.Equal(t, fd2.Period, "2016.09")
+	assert.Equal(t, fd2.DataValue, "1070.874")
 	assert.Equal(t, fd2.Suppressed, "suppressed2")
 	assert.Equal(t, fd2.Status, "status2")
 	assert.Equal(t, fd2.Units, "units2")
-	assert.Equal(t, fd2.Magnitude, "magnitude2")
+	assert.Equal(t, fd2.Magnitude, "2")
 	assert.Equal(t, fd2.Subject, "subject2")
 	assert.Equal(t, fd2.Group, "group2")
 	assert.Equal(t, fd2.SeriesTitle1, "seriesTitle12")
@@ -182,12 +186,12 @@ func TestIndex(t *testing.T) {
 	fd3 := result.Data[0]
 	assert.Equal(t, fd3.ID, id3)
 	assert.Equal(t, fd3.SeriesReference, "sr3")
-	assert.Equal(t, fd3.Period, "period3")
-	assert.Equal(t, fd3.DataValue, "dataValue3")
+	assert.Equal(t, fd3.Period, "2016.12")
+	assert.Equal(t, fd3.DataValue, "1054.408")
 	assert.Equal(t, fd3.Suppressed, "suppressed3")
 	assert.Equal(t, fd3.Status, "status3")
 	assert.Equal(t, fd3.Units, "units3")
-	assert.Equal(t, fd3.Magnitude, "magnitude3")
+	assert.Equal(t, fd3.Magnitude, "3")
 	assert.Equal(t, fd3.Subject, "subject3")
 	assert.Equal(t, fd3.Group, "group3")
 	assert.Equal(t, fd3.SeriesTitle1, "seriesTitle13")
@@ -198,12 +202,12 @@ func TestIndex(t *testing.T) {
 	fd4 := result.Data[1]
 	assert.Equal(t, fd4.ID, id4)
 	assert.Equal(t, fd4.SeriesReference, "sr4")
-	assert.Equal(t, fd4.Period, "period4")
-	assert.Equal(t, fd4.DataValue, "dataValue4")
+	assert.Equal(t, fd4.Period, "2017.03")
+	assert.Equal(t, fd4.DataValue, "1010.665")
 	assert.Equal(t, fd4.Suppressed, "suppressed4")
 	assert.Equal(t, fd4.Status, "status4")
 	assert.Equal(t, fd4.Units, "units4")
-	assert.Equal(t, fd4.Magnitude, "magnitude4")
+	assert.Equal(t, fd4.Magnitude, "4")
 	assert.Equal(t, fd4.Subject, "subject4")
 	assert.Equal(t, fd4.Group, "group4")
 	assert.Equal(t, fd4.SeriesTitle1, "seriesTitle14")
@@ -256,12 +260,12 @@ func TestCreate_Update_Delete(t *testing.T) {
 	res := httptest.NewRecorder()
 	data := `{
 		"seriesReference":"newSr",
-		"period":"newPeriod",
-		"dataValue":"newDataValue",
-		"suppressed":"newSuppressed",
-		"status":"newStatus",
+		"period":"2016.06",
+		"dataValue":"1116.386",
+		"suppressed":"Y",
+		"status":"F",
 		"units":"newUnits",
-		"magnitude":"newMagnitude",
+		"magnitude":"6",
 		"subject":"newSubject",
 		"group":"newGroup",
 		"seriesTitle1":"newSeriesTitle1",
@@ -277,12 +281,12 @@ func TestCreate_Update_Delete(t *testing.T) {
 	result := struct {
 		Status  bool
 		Message string
-		Data    map[string]string
+		Data    financial.CreateFinancialDataResult
 	}{}
 	err = json.Unmarshal(res.Body.Bytes(), &result)
 	assert.Nil(t, err)
-	id, ok := result.Data["id"]
-	assert.True(t, ok)
+	id := result.Data.ID
+	assert.NotEmpty(t, id)
 	//here we get the data by id from the db to check if it is inserted
 	coll = mongoDBClient.Database(dbName).Collection("financialData")
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -295,12 +299,12 @@ func TestCreate_Update_Delete(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, m.ID.Hex(), id)
 	assert.Equal(t, m.SeriesReference, "newSr")
-	assert.Equal(t, m.Period, "newPeriod")
-	assert.Equal(t, m.DataValue, "newDataValue")
-	assert.Equal(t, m.Suppressed, "newSuppressed")
-	assert.Equal(t, m.Status, "newStatus")
+	assert.Equal(t, m.Period, financial.Period{Year: 2016, Quarter: 2})
+	assert.Equal(t, m.DataValue.String(), "1116.386")
+	assert.Equal(t, m.Suppressed, "Y")
+	assert.Equal(t, m.Status, "F")
 	assert.Equal(t, m.Units, "newUnits")
-	assert.Equal(t, m.Magnitude, "newMagnitude")
+	assert.Equal(t, m.Magnitude, 6)
 	assert.Equal(t, m.Subject, "newSubject")
 	assert.Equal(t, m.Group, "newGroup")
 	assert.Equal(t, m.SeriesTitle1, "newSeriesTitle1")
@@ -308,18 +312,19 @@ func TestCreate_Update_Delete(t *testing.T) {
 	assert.Equal(t, m.SeriesTitle3, "newSeriesTitle3")
 	assert.Equal(t, m.SeriesTitle4, "newSeriesTitle4")
 	assert.Equal(t, m.SeriesTitle5, "newSeriesTitle5")
+	assert.Equal(t, m.Revision, int64(1))
 
 	//now we try to update the same doc
 	res = httptest.NewRecorder()
 	data = fmt.Sprintf(`{
 		"id":"%s",
 		"seriesReference":"updatedSr",
-		"period":"updatedPeriod",
-		"dataValue":"updatedDataValue",
-		"suppressed":"updatedSuppressed",
-		"status":"updatedStatus",
+		"period":"2016.09",
+		"dataValue":"1070.874",
+		"suppressed":"",
+		"status":"R",
 		"units":"updatedUnits",
-		"magnitude":"updatedMagnitude",
+		"magnitude":"3",
 		"subject":"updatedSubject",
 		"group":"updatedGroup",
 		"seriesTitle1":"updatedSeriesTitle1",
@@ -331,13 +336,18 @@ func TestCreate_Update_Delete(t *testing.T) {
 	req = httptest.NewRequest(http.MethodPost, "/api/v1/financial/update", bytes.NewReader(body))
 	engine.ServeHTTP(res, req)
 	assert.Equal(t, res.Code, http.StatusOK)
-	result = struct {
+	updateResult := struct {
 		Status  bool
 		Message string
-		Data    map[string]string
+		Data    financial.SingleFinancialDataResult
 	}{}
-	err = json.Unmarshal(res.Body.Bytes(), &result)
+	err = json.Unmarshal(res.Body.Bytes(), &updateResult)
 	assert.Nil(t, err)
+	//the update returns the written record with its next revision
+	assert.Equal(t, updateResult.Data.ID, id)
+	assert.Equal(t, updateResult.Data.SeriesReference, "updatedSr")
+	assert.Equal(t, updateResult.Data.Revision, int64(2))
+	assert.Equal(t, res.Header().Get("ETag"), `"2"`)
 	//here we get the data by id from the db to check if it is updated
 	coll = mongoDBClient.Database(dbName).Collection("financialData")
 	assert.Nil(t, err)
@@ -348,12 +358,12 @@ func TestCreate_Update_Delete(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, m.ID.Hex(), id)
 	assert.Equal(t, m.SeriesReference, "updatedSr")
-	assert.Equal(t, m.Period, "updatedPeriod")
-	assert.Equal(t, m.DataValue, "updatedDataValue")
-	assert.Equal(t, m.Suppressed, "updatedSuppressed")
-	assert.Equal(t, m.Status, "updatedStatus")
+	assert.Equal(t, m.Period, financial.Period{Year: 2016, Quarter: 3})
+	assert.Equal(t, m.DataValue.String(), "1070.874")
+	assert.Equal(t, m.Suppressed, "")
+	assert.Equal(t, m.Status, "R")
 	assert.Equal(t, m.Units, "updatedUnits")
-	assert.Equal(t, m.Magnitude, "updatedMagnitude")
+	assert.Equal(t, m.Magnitude, 3)
 	assert.Equal(t, m.Subject, "updatedSubject")
 	assert.Equal(t, m.Group, "updatedGroup")
 	assert.Equal(t, m.SeriesTitle1, "updatedSeriesTitle1")
@@ -362,6 +372,7 @@ func TestCreate_Update_Delete(t *testing.T) {
 	assert.Equal(t, m.SeriesTitle4, "updatedSeriesTitle4")
 	//this should not be updated because we did not sent it
 	assert.Equal(t, m.SeriesTitle5, "newSeriesTitle5")
+	assert.Equal(t, m.Revision, int64(2))
 
 	//now we try to delete the same doc
 	res = httptest.NewRecorder()
@@ -372,16 +383,1691 @@ func TestCreate_Update_Delete(t *testing.T) {
 	req = httptest.NewRequest(http.MethodPost, "/api/v1/financial/delete", bytes.NewReader(body))
 	engine.ServeHTTP(res, req)
 	assert.Equal(t, res.Code, http.StatusOK)
-	result = struct {
+	deleteResult := struct {
 		Status  bool
 		Message string
-		Data    map[string]string
+		Data    map[string]interface{}
 	}{}
-	err = json.Unmarshal(res.Body.Bytes(), &result)
+	err = json.Unmarshal(res.Body.Bytes(), &deleteResult)
 	assert.Nil(t, err)
-	//here we get the data by id from the db to check if it is deleted
+	assert.True(t, deleteResult.Status)
+	assert.Empty(t, deleteResult.Data)
+	//here we get the data by id from the db to check if it is marked deleted
 	coll = mongoDBClient.Database(dbName).Collection("financialData")
 	assert.Nil(t, err)
 	dbResult = coll.FindOne(ctx, filter)
-	assert.Equal(t, dbResult.Err(), mongo.ErrNoDocuments)
+	assert.Nil(t, dbResult.Err())
+	m = financial.FinancialModel{}
+	err = dbResult.Decode(&m)
+	assert.Nil(t, err)
+	assert.NotNil(t, m.DeletedAt)
+}
+
+func decimal(t *testing.T, s string) *primitive.Decimal128 {
+	d, err := primitive.ParseDecimal128(s)
+	assert.Nil(t, err)
+	return &d
+}
+
+func TestCreate_InvalidTypes(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	invalidBodies := []string{
+		`{"seriesReference":"sr","period":"2016.05","dataValue":"1.5","magnitude":"6"}`,
+		`{"seriesReference":"sr","period":"2016.06","dataValue":"abc","magnitude":"6"}`,
+		`{"seriesReference":"sr","period":"2016.06","dataValue":"1.5","magnitude":"six"}`,
+	}
+	for _, data := range invalidBodies {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/financial/create", bytes.NewReader([]byte(data)))
+		engine.ServeHTTP(res, req)
+		assert.Equal(t, res.Code, http.StatusBadRequest, data)
+	}
+}
+
+func TestCreate_Validation(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	cfg.Set("http.maxBodyBytes", 1024)
+	defer cfg.Set("http.maxBodyBytes", 0)
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	send := func(method, url, body string) (int, response.ApiResponse) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		engine.ServeHTTP(res, req)
+		result := response.ApiResponse{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result
+	}
+
+	code, result := send(http.MethodPost, "/api/v1/financial", fmt.Sprintf(`{
+		"period":"2016.05",
+		"dataValue":"NaN",
+		"suppressed":"N",
+		"status":"X",
+		"magnitude":"16",
+		"seriesTitle1":"%s"
+	}`, strings.Repeat("a", 257)))
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.False(t, result.Status)
+	assert.Equal(t, result.Data, map[string]interface{}{
+		"seriesReference": "is required",
+		"period":          "must be a period like 2016.06",
+		"dataValue":       "must be a decimal number",
+		"suppressed":      "must be one of Y",
+		"status":          "must be one of F, R, C",
+		"magnitude":       "must be a whole number from 0 to 15",
+		"seriesTitle1":    "must be at most 256 characters",
+	})
+
+	//the legacy routes are validated the same way
+	code, result = send(http.MethodPost, "/api/v1/financial/create", `{"seriesReference":"sr","period":"2016.06","magnitude":"6","unit":"Dollars"}`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, result.Data, map[string]interface{}{"unit": "is not a known field"})
+
+	code, result = send(http.MethodPost, "/api/v1/financial", `{"seriesReference":"sr","period":2016.06,"magnitude":"6"}`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, result.Data, map[string]interface{}{"period": "must be a string"})
+
+	//status and suppressed can be cleared, the series reference can not
+	code, result = send(http.MethodPatch, "/api/v1/financial/"+primitive.NewObjectID().Hex(), `{"seriesReference":"","status":"","suppressed":""}`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, result.Data, map[string]interface{}{"seriesReference": "must be at least 1 characters"})
+
+	code, result = send(http.MethodPost, "/api/v1/financial", fmt.Sprintf(`{"seriesReference":"%s"}`, strings.Repeat("a", 2048)))
+	assert.Equal(t, code, http.StatusRequestEntityTooLarge)
+	assert.False(t, result.Status)
+}
+
+func TestMigrateFinancialData(t *testing.T) {
+	container := di.NewContainer()
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+	quarantine := mongoDBClient.Database(dbName).Collection("financialDataQuarantine")
+	_, err = quarantine.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	//documents written before the typed fields stored everything as strings,
+	//and the old update wrote the subject as Subject
+	legacy, err := coll.InsertOne(ctx, bson.M{
+		"seriesReference": "BDCQ.SF1AA2CA",
+		"period":          "2016.06",
+		"dataValue":       "1116.386",
+		"magnitude":       "6",
+		"subject":         "oldSubject",
+		"Subject":         "updatedSubject",
+	})
+	assert.Nil(t, err)
+	invalid, err := coll.InsertOne(ctx, bson.M{
+		"seriesReference": "newSeriesReference",
+		"period":          "newPeriod",
+		"dataValue":       "newDataValue",
+		"magnitude":       "newMagnitude",
+	})
+	assert.Nil(t, err)
+	suppressed, err := coll.InsertOne(ctx, bson.M{
+		"seriesReference": "BDCQ.SF1AA2CT",
+		"period":          "2020.03",
+		"dataValue":       "",
+		"magnitude":       "6",
+	})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	err = financialService.MigrateFinancialData(ctx)
+	assert.Nil(t, err)
+
+	m := financial.FinancialModel{}
+	err = coll.FindOne(ctx, bson.M{"_id": legacy.InsertedID}).Decode(&m)
+	assert.Nil(t, err)
+	assert.Equal(t, m.Period, financial.Period{Year: 2016, Quarter: 2})
+	assert.Equal(t, m.DataValue.String(), "1116.386")
+	assert.Equal(t, m.Magnitude, 6)
+	assert.Equal(t, m.Subject, "updatedSubject")
+	count, err := coll.CountDocuments(ctx, bson.M{"Subject": bson.M{"$exists": true}})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(0))
+
+	m = financial.FinancialModel{}
+	err = coll.FindOne(ctx, bson.M{"_id": suppressed.InsertedID}).Decode(&m)
+	assert.Nil(t, err)
+	assert.Equal(t, m.Period, financial.Period{Year: 2020, Quarter: 1})
+	assert.Nil(t, m.DataValue)
+
+	count, err = coll.CountDocuments(ctx, bson.M{"period": bson.M{"$type": "string"}})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(0))
+
+	//what cannot be converted is moved out of the way of the queries
+	count, err = coll.CountDocuments(ctx, bson.M{"_id": invalid.InsertedID})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(0))
+	quarantined := struct {
+		Document bson.M `bson:"document"`
+		Error    string `bson:"error"`
+	}{}
+	err = quarantine.FindOne(ctx, bson.M{"_id": invalid.InsertedID}).Decode(&quarantined)
+	assert.Nil(t, err)
+	assert.Equal(t, quarantined.Document["period"], "newPeriod")
+	assert.NotEmpty(t, quarantined.Error)
+}
+
+func TestCreate_Conflict_And_Upsert(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	err = financialService.EnsureIndexes(ctx)
+	assert.Nil(t, err)
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	data := `{"seriesReference":"BDCQ.SF1AA2CA","period":"2016.06","dataValue":"1116.386","magnitude":"6"}`
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/financial/create", bytes.NewReader([]byte(data)))
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+
+	//the same seriesReference and period can not be created twice
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/financial/create", bytes.NewReader([]byte(data)))
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusConflict)
+
+	//upsert overwrites the existing document instead of adding another one
+	err = financialService.UpsertFinancialData(ctx, financial.FinancialModel{
+		SeriesReference: "BDCQ.SF1AA2CA",
+		Period:          financial.Period{Year: 2016, Quarter: 2},
+		DataValue:       decimal(t, "1200.5"),
+		Magnitude:       6,
+	})
+	assert.Nil(t, err)
+	err = financialService.UpsertFinancialData(ctx, financial.FinancialModel{
+		SeriesReference: "BDCQ.SF1AA2CA",
+		Period:          financial.Period{Year: 2016, Quarter: 3},
+		DataValue:       decimal(t, "1070.874"),
+		Magnitude:       6,
+	})
+	assert.Nil(t, err)
+	count, err := coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(2))
+	m := financial.FinancialModel{}
+	err = coll.FindOne(ctx, bson.M{"period": financial.Period{Year: 2016, Quarter: 2}}).Decode(&m)
+	assert.Nil(t, err)
+	assert.Equal(t, m.DataValue.String(), "1200.5")
+	assert.False(t, m.ID.IsZero())
+}
+
+func TestIndex_Filters(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	err = financialService.EnsureIndexes(ctx)
+	assert.Nil(t, err)
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	periods := []financial.Period{{Year: 2016, Quarter: 2}, {Year: 2016, Quarter: 3}, {Year: 2016, Quarter: 4}, {Year: 2017, Quarter: 1}}
+	for _, sr := range []string{"sr1", "sr2"} {
+		for i, period := range periods {
+			_, err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+				SeriesReference: sr,
+				Period:          period,
+				DataValue:       decimal(t, "10"),
+				Status:          []string{"F", "R"}[i%2],
+				Units:           "Dollars",
+				Magnitude:       6,
+				Subject:         "subject",
+				Group:           "group_" + sr,
+				SeriesTitle1:    "title1",
+				SeriesTitle2:    "title2_" + sr,
+			})
+			assert.Nil(t, err)
+		}
+	}
+
+	list := func(query url.Values) (int, []financial.SingleFinancialDataResult) {
+		query.Set("pageSize", "100")
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/financial?"+query.Encode(), nil)
+		engine.ServeHTTP(res, req)
+		result := struct {
+			Status  bool
+			Message string
+			Data    []financial.SingleFinancialDataResult
+		}{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result.Data
+	}
+
+	code, data := list(url.Values{"seriesReference": {"sr1"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 4)
+	for _, fd := range data {
+		assert.Equal(t, fd.SeriesReference, "sr1")
+	}
+
+	code, data = list(url.Values{"periodFrom": {"2016.09"}, "periodTo": {"2016.12"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 4)
+	for _, fd := range data {
+		assert.Contains(t, []string{"2016.09", "2016.12"}, fd.Period)
+	}
+
+	code, data = list(url.Values{"periodFrom": {"2016.12"}, "status": {"R"}, "seriesTitle2": {"title2_sr2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 1)
+	assert.Equal(t, data[0].SeriesReference, "sr2")
+	assert.Equal(t, data[0].Period, "2017.03")
+
+	code, data = list(url.Values{"units": {"Dollars"}, "subject": {"subject"}, "group": {"group_sr2"}, "seriesTitle1": {"title1"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 4)
+
+	code, data = list(url.Values{"units": {"Percent"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 0)
+
+	code, _ = list(url.Values{"periodFrom": {"2016.05"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	code, _ = list(url.Values{"periodFrom": {"2017.03"}, "periodTo": {"2016.06"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestIndex_SortAndCursor(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	//the suppressed value has no data value, two others share the same one
+	ids := make(map[string]string)
+	for _, d := range []struct{ sr, value string }{{"A", "5"}, {"B", "3"}, {"C", ""}, {"D", "3"}, {"E", "1"}} {
+		var value *primitive.Decimal128
+		if d.value != "" {
+			value = decimal(t, d.value)
+		}
+		id, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: d.sr,
+			Period:          financial.Period{Year: 2016, Quarter: 2},
+			DataValue:       value,
+			Magnitude:       6,
+		})
+		assert.Nil(t, err)
+		ids[d.sr] = id
+	}
+
+	type listResult struct {
+		Status  bool
+		Message string
+		Data    []financial.SingleFinancialDataResult
+		Meta    financial.ListMeta
+	}
+	list := func(query url.Values) (int, listResult) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/financial?"+query.Encode(), nil)
+		engine.ServeHTTP(res, req)
+		result := listResult{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result
+	}
+	refs := func(data []financial.SingleFinancialDataResult) []string {
+		res := make([]string, len(data))
+		for i, fd := range data {
+			res[i] = fd.SeriesReference
+		}
+		return res
+	}
+
+	//descending, ties ordered by id and the missing value last
+	code, result := list(url.Values{"sort": {"-dataValue"}, "pageSize": {"2"}, "withTotal": {"true"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"A", "D"})
+	assert.Equal(t, *result.Meta.Total, int64(5))
+	assert.Empty(t, result.Meta.PrevCursor)
+	assert.NotEmpty(t, result.Meta.NextCursor)
+
+	code, result = list(url.Values{"cursor": {result.Meta.NextCursor}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"B", "E"})
+	assert.Nil(t, result.Meta.Total)
+
+	code, result = list(url.Values{"cursor": {result.Meta.NextCursor}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"C"})
+	assert.Empty(t, result.Meta.NextCursor)
+	assert.NotEmpty(t, result.Meta.PrevCursor)
+
+	code, result = list(url.Values{"cursor": {result.Meta.PrevCursor}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"B", "E"})
+	code, result = list(url.Values{"cursor": {result.Meta.PrevCursor}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"A", "D"})
+	assert.Empty(t, result.Meta.PrevCursor)
+
+	//ascending, the missing value first
+	code, result = list(url.Values{"sort": {"dataValue"}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"C", "E"})
+	code, result = list(url.Values{"sort": {"dataValue"}, "cursor": {result.Meta.NextCursor}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"B", "D"})
+	code, result = list(url.Values{"cursor": {result.Meta.NextCursor}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"A"})
+	code, result = list(url.Values{"cursor": {result.Meta.PrevCursor}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"B", "D"})
+
+	//pages still work and are ordered by id by default
+	code, result = list(url.Values{"page": {"1"}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, refs(result.Data), []string{"C", "D"})
+	assert.Equal(t, result.Data[0].ID, ids["C"])
+	assert.NotEmpty(t, result.Meta.PrevCursor)
+	assert.NotEmpty(t, result.Meta.NextCursor)
+
+	code, _ = list(url.Values{"sort": {"unknown"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	code, _ = list(url.Values{"cursor": {"invalid"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	code, result = list(url.Values{"sort": {"period"}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	code, _ = list(url.Values{"sort": {"-period"}, "cursor": {result.Meta.NextCursor}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	//period values are documents, the cursors of a period sort still work
+	code, _ = list(url.Values{"cursor": {result.Meta.NextCursor}})
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestShow_And_IDs(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	var ids []string
+	for _, sr := range []string{"sr1", "sr2", "sr3"} {
+		id, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: sr,
+			Period:          financial.Period{Year: 2016, Quarter: 2},
+			DataValue:       decimal(t, "1116.386"),
+			Magnitude:       6,
+		})
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/financial/"+ids[1], nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	single := struct {
+		Status  bool
+		Message string
+		Data    financial.SingleFinancialDataResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &single)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Data.ID, ids[1])
+	assert.Equal(t, single.Data.SeriesReference, "sr2")
+	assert.Equal(t, single.Data.DataValue, "1116.386")
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/financial/"+primitive.NewObjectID().Hex(), nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusNotFound)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/financial/invalid", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+
+	//the records come back in the order of the ids
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/financial?ids="+ids[2]+","+ids[0], nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	list := struct {
+		Status  bool
+		Message string
+		Data    []financial.SingleFinancialDataResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &list)
+	assert.Nil(t, err)
+	assert.Equal(t, len(list.Data), 2)
+	assert.Equal(t, list.Data[0].ID, ids[2])
+	assert.Equal(t, list.Data[1].ID, ids[0])
+
+	missingID := primitive.NewObjectID().Hex()
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/financial?ids="+ids[0]+","+missingID, nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	missing := struct {
+		Status bool
+		Data   struct {
+			MissingIds []string
+		}
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &missing)
+	assert.Nil(t, err)
+	assert.Equal(t, missing.Data.MissingIds, []string{missingID})
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/financial?ids="+ids[0]+",invalid", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+}
+
+func TestSearch(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	err = financialService.EnsureIndexes(ctx)
+	assert.Nil(t, err)
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	for _, d := range []struct{ sr, status, title1, title2 string }{
+		{"sr1", "F", "Sales (operating income)", "Forestry and Logging"},
+		{"sr2", "R", "Sales (operating income)", "Forestry and Logging"},
+		{"sr3", "F", "Salaries and wages", "Mining"},
+		{"sr4", "F", "Sales (operating income)", "Mining"},
+	} {
+		_, err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: d.sr,
+			Period:          financial.Period{Year: 2016, Quarter: 2},
+			DataValue:       decimal(t, "10"),
+			Status:          d.status,
+			Magnitude:       6,
+			Subject:         "Business Data Collection - BDC",
+			SeriesTitle1:    d.title1,
+			SeriesTitle2:    d.title2,
+		})
+		assert.Nil(t, err)
+	}
+
+	search := func(query url.Values) (int, []financial.SingleFinancialDataResult) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/financial/search?"+query.Encode(), nil)
+		engine.ServeHTTP(res, req)
+		result := struct {
+			Status  bool
+			Message string
+			Data    []financial.SingleFinancialDataResult
+		}{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result.Data
+	}
+
+	code, data := search(url.Values{"q": {"Forestry"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 2)
+
+	//rows matching both words rank before rows matching one of them
+	code, data = search(url.Values{"q": {"operating income mining"}, "pageSize": {"10"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 4)
+	assert.Equal(t, data[0].SeriesReference, "sr4")
+
+	code, data = search(url.Values{"q": {"forestry"}, "status": {"R"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 1)
+	assert.Equal(t, data[0].SeriesReference, "sr2")
+
+	code, data = search(url.Values{"q": {"unrelated"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 0)
+
+	code, _ = search(url.Values{"q": {" "}})
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestSeries_And_Observations(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	//periods are inserted out of order on purpose
+	for _, d := range []struct {
+		sr     string
+		period financial.Period
+		value  string
+	}{
+		{"BDCQ.SF1AA2CA", financial.Period{Year: 2016, Quarter: 4}, "1054.408"},
+		{"BDCQ.SF1AA2CA", financial.Period{Year: 2016, Quarter: 2}, "1116.386"},
+		{"BDCQ.SF1AA2CA", financial.Period{Year: 2017, Quarter: 1}, "1010.665"},
+		{"BDCQ.SF1AA2CA", financial.Period{Year: 2016, Quarter: 3}, "1070.874"},
+		{"BDCQ.SF1AACA", financial.Period{Year: 2016, Quarter: 2}, "12.5"},
+	} {
+		_, err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: d.sr,
+			Period:          d.period,
+			DataValue:       decimal(t, d.value),
+			Status:          "F",
+			Units:           "Dollars",
+			Magnitude:       6,
+			Subject:         "Business Data Collection - BDC",
+			Group:           "Industry by financial variable (NZSIOC Level 2)",
+			SeriesTitle1:    "Sales (operating income)",
+			SeriesTitle2:    "Forestry and Logging",
+		})
+		assert.Nil(t, err)
+	}
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/series?pageSize=10", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	series := struct {
+		Status  bool
+		Message string
+		Data    []financial.SingleSeriesResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &series)
+	assert.Nil(t, err)
+	assert.Equal(t, len(series.Data), 2)
+	s := series.Data[0]
+	assert.Equal(t, s.SeriesReference, "BDCQ.SF1AA2CA")
+	assert.Equal(t, s.ObservationCount, 4)
+	assert.Equal(t, s.FirstPeriod, "2016.06")
+	assert.Equal(t, s.LastPeriod, "2017.03")
+	assert.Equal(t, s.Units, "Dollars")
+	assert.Equal(t, s.Magnitude, "6")
+	assert.Equal(t, s.SeriesTitle2, "Forestry and Logging")
+	assert.Equal(t, series.Data[1].SeriesReference, "BDCQ.SF1AACA")
+	assert.Equal(t, series.Data[1].ObservationCount, 1)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/series/BDCQ.SF1AA2CA/observations", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	observations := struct {
+		Status  bool
+		Message string
+		Data    []financial.SingleObservationResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &observations)
+	assert.Nil(t, err)
+	assert.Equal(t, len(observations.Data), 4)
+	var periods []string
+	for _, o := range observations.Data {
+		periods = append(periods, o.Period)
+	}
+	assert.Equal(t, periods, []string{"2016.06", "2016.09", "2016.12", "2017.03"})
+	assert.Equal(t, observations.Data[0].DataValue, "1116.386")
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/series/BDCQ.SF1AA2CA/observations?periodFrom=2016.12", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	err = json.Unmarshal(res.Body.Bytes(), &observations)
+	assert.Nil(t, err)
+	assert.Equal(t, len(observations.Data), 2)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/series/UNKNOWN/observations", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusNotFound)
+}
+
+func TestAggregate(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	for _, d := range []struct {
+		sr, industry, status, value string
+		period                      financial.Period
+	}{
+		{"sr1", "Forestry", "F", "10.5", financial.Period{Year: 2016, Quarter: 2}},
+		{"sr1", "Forestry", "F", "20", financial.Period{Year: 2016, Quarter: 3}},
+		{"sr1", "Forestry", "R", "30", financial.Period{Year: 2017, Quarter: 1}},
+		{"sr2", "Mining", "F", "5", financial.Period{Year: 2016, Quarter: 2}},
+		//suppressed, it has no value
+		{"sr2", "Mining", "C", "", financial.Period{Year: 2017, Quarter: 1}},
+	} {
+		var value *primitive.Decimal128
+		if d.value != "" {
+			value = decimal(t, d.value)
+		}
+		_, err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: d.sr,
+			Period:          d.period,
+			DataValue:       value,
+			Status:          d.status,
+			Magnitude:       6,
+			SeriesTitle2:    d.industry,
+		})
+		assert.Nil(t, err)
+	}
+
+	aggregate := func(query url.Values) (int, []financial.SingleAggregationResult) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/financial/aggregate?"+query.Encode(), nil)
+		engine.ServeHTTP(res, req)
+		result := struct {
+			Status  bool
+			Message string
+			Data    []financial.SingleAggregationResult
+		}{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result.Data
+	}
+
+	code, data := aggregate(url.Values{"groupBy": {"seriesTitle2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 2)
+	assert.Equal(t, data[0].Key, "Forestry")
+	assert.Equal(t, data[0].Count, 3)
+	assert.Equal(t, data[0].Sum, "60.5")
+	assert.Equal(t, data[0].Min, "10.5")
+	assert.Equal(t, data[0].Max, "30")
+	assert.NotEmpty(t, data[0].Avg)
+	assert.Equal(t, data[1].Key, "Mining")
+	assert.Equal(t, data[1].Count, 1)
+	assert.Equal(t, data[1].Sum, "5")
+
+	code, data = aggregate(url.Values{"groupBy": {"year"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 2)
+	assert.Equal(t, data[0].Key, "2016")
+	assert.Equal(t, data[0].Sum, "35.5")
+	assert.Equal(t, data[1].Key, "2017")
+	assert.Equal(t, data[1].Count, 1)
+
+	//a group of suppressed values only has no statistics
+	code, data = aggregate(url.Values{"groupBy": {"status"}, "seriesReference": {"sr2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 2)
+	assert.Equal(t, data[0].Key, "C")
+	assert.Equal(t, data[0].Count, 0)
+	assert.Empty(t, data[0].Sum)
+	assert.Empty(t, data[0].Avg)
+
+	code, _ = aggregate(url.Values{"groupBy": {"unknown"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	code, _ = aggregate(url.Values{})
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestSeries_Changes(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	for _, d := range []struct {
+		sr     string
+		period financial.Period
+		value  string
+	}{
+		{"sr1", financial.Period{Year: 2016, Quarter: 1}, "100"},
+		{"sr1", financial.Period{Year: 2016, Quarter: 2}, "110"},
+		//2016.09 is missing
+		{"sr1", financial.Period{Year: 2016, Quarter: 4}, "121.5"},
+		{"sr1", financial.Period{Year: 2017, Quarter: 1}, ""},
+		{"sr1", financial.Period{Year: 2017, Quarter: 2}, "132"},
+		{"sr2", financial.Period{Year: 2016, Quarter: 2}, "0"},
+		{"sr2", financial.Period{Year: 2016, Quarter: 3}, "5"},
+	} {
+		var value *primitive.Decimal128
+		if d.value != "" {
+			value = decimal(t, d.value)
+		}
+		_, err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: d.sr,
+			Period:          d.period,
+			DataValue:       value,
+			Status:          "F",
+			Magnitude:       6,
+		})
+		assert.Nil(t, err)
+	}
+
+	changes := func(url string) (int, []financial.SingleChangeResult) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		engine.ServeHTTP(res, req)
+		result := struct {
+			Status  bool
+			Message string
+			Data    []financial.SingleChangeResult
+		}{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result.Data
+	}
+
+	code, data := changes("/api/v1/series/sr1/changes")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 5)
+	assert.Equal(t, data[0].QuarterOnQuarter.Status, financial.ChangeStatusNoPriorPeriod)
+	assert.Equal(t, data[1].QuarterOnQuarter, financial.PeriodChange{
+		PriorPeriod:   "2016.03",
+		PriorValue:    "100",
+		Change:        "10",
+		PercentChange: "10.00",
+		Status:        financial.ChangeStatusOK,
+	})
+	//the delta is not taken against 2016.06, the closest earlier period
+	assert.Equal(t, data[2].Period, "2016.12")
+	assert.Equal(t, data[2].QuarterOnQuarter.PriorPeriod, "2016.09")
+	assert.Equal(t, data[2].QuarterOnQuarter.Status, financial.ChangeStatusNoPriorPeriod)
+	assert.Empty(t, data[2].QuarterOnQuarter.Change)
+
+	//prior periods before periodFrom are still used
+	code, data = changes("/api/v1/series/sr1/changes?periodFrom=2017.03")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 2)
+	assert.Equal(t, data[0].Period, "2017.03")
+	assert.Equal(t, data[0].QuarterOnQuarter.PriorValue, "121.5")
+	assert.Equal(t, data[0].QuarterOnQuarter.Status, financial.ChangeStatusSuppressed)
+	assert.Equal(t, data[0].YearOnYear.Status, financial.ChangeStatusSuppressed)
+	assert.Equal(t, data[1].QuarterOnQuarter.Status, financial.ChangeStatusSuppressed)
+	assert.Equal(t, data[1].YearOnYear, financial.PeriodChange{
+		PriorPeriod:   "2016.06",
+		PriorValue:    "110",
+		Change:        "22",
+		PercentChange: "20.00",
+		Status:        financial.ChangeStatusOK,
+	})
+
+	code, data = changes("/api/v1/series/changes?pageSize=10")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(data), 7)
+	assert.Equal(t, data[6].SeriesReference, "sr2")
+	assert.Equal(t, data[6].QuarterOnQuarter.Change, "5")
+	assert.Empty(t, data[6].QuarterOnQuarter.PercentChange)
+	assert.Equal(t, data[6].QuarterOnQuarter.Status, financial.ChangeStatusZeroBase)
+
+	code, _ = changes("/api/v1/series/unknown/changes")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _ = changes("/api/v1/series/changes?periodFrom=2016")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestScaled(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	id, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
+		SeriesReference: "sr1",
+		Period:          financial.Period{Year: 2016, Quarter: 2},
+		DataValue:       decimal(t, "1116.386"),
+		Units:           "Dollars",
+		Magnitude:       6,
+	})
+	assert.Nil(t, err)
+	_, err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+		SeriesReference: "sr2",
+		Period:          financial.Period{Year: 2016, Quarter: 2},
+		Suppressed:      "Y",
+		Units:           "Dollars",
+		Magnitude:       6,
+	})
+	assert.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/financial/"+id+"?scaled=true", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	single := struct {
+		Status  bool
+		Message string
+		Data    financial.SingleFinancialDataResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &single)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Data.DataValue, "1116.386")
+	assert.Equal(t, single.Data.Magnitude, "6")
+	assert.Equal(t, single.Data.ScaledValue, "1116386000")
+	assert.Equal(t, single.Data.ScaledUnits, "Dollars")
+	assert.Equal(t, single.Data.FormattedValue, "$1.12bn")
+
+	//not scaled unless asked for
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/financial/"+id, nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.NotContains(t, res.Body.String(), "scaledValue")
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/financial?scaled=true&sort=seriesReference", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	list := struct {
+		Status  bool
+		Message string
+		Data    []financial.SingleFinancialDataResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &list)
+	assert.Nil(t, err)
+	assert.Equal(t, len(list.Data), 2)
+	assert.Equal(t, list.Data[0].FormattedValue, "$1.12bn")
+	assert.Empty(t, list.Data[1].ScaledValue)
+	assert.Empty(t, list.Data[1].FormattedValue)
+}
+
+func TestFacets(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	for i, d := range []struct {
+		status, units, industry string
+	}{
+		{"F", "Dollars", "Forestry"},
+		{"F", "Dollars", "Mining"},
+		{"R", "Dollars", "Mining"},
+		{"F", "Number", "Mining"},
+		{"C", "Number", ""},
+	} {
+		_, err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: fmt.Sprintf("sr%d", i),
+			Period:          financial.Period{Year: 2016, Quarter: 2},
+			DataValue:       decimal(t, "1"),
+			Status:          d.status,
+			Units:           d.units,
+			Magnitude:       6,
+			SeriesTitle2:    d.industry,
+		})
+		assert.Nil(t, err)
+	}
+
+	facets := func(query string) (int, map[string][]financial.SingleFacetResult) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/financial/facets"+query, nil)
+		engine.ServeHTTP(res, req)
+		result := struct {
+			Status  bool
+			Message string
+			Data    map[string][]financial.SingleFacetResult
+		}{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result.Data
+	}
+
+	code, data := facets("")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, data["status"], []financial.SingleFacetResult{
+		{Value: "F", Count: 3},
+		{Value: "C", Count: 1},
+		{Value: "R", Count: 1},
+	})
+	assert.Equal(t, data["units"], []financial.SingleFacetResult{
+		{Value: "Dollars", Count: 3},
+		{Value: "Number", Count: 2},
+	})
+	//records without a title are not a value to drill down to
+	assert.Equal(t, data["seriesTitle2"], []financial.SingleFacetResult{
+		{Value: "Mining", Count: 3},
+		{Value: "Forestry", Count: 1},
+	})
+	assert.Equal(t, data["seriesTitle5"], []financial.SingleFacetResult{})
+	assert.Equal(t, len(data), 9)
+
+	code, data = facets("?units=Dollars&seriesTitle2=Mining")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, data["status"], []financial.SingleFacetResult{
+		{Value: "F", Count: 1},
+		{Value: "R", Count: 1},
+	})
+	assert.Equal(t, data["units"], []financial.SingleFacetResult{{Value: "Dollars", Count: 2}})
+
+	code, _ = facets("?periodTo=2016.05")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestIndex_Fields(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	var ids []string
+	for _, d := range []struct{ sr, value string }{{"A", "5"}, {"B", "3"}, {"C", "1"}} {
+		id, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: d.sr,
+			Period:          financial.Period{Year: 2016, Quarter: 2},
+			DataValue:       decimal(t, d.value),
+			Units:           "Dollars",
+			Magnitude:       6,
+			SeriesTitle1:    "Sales (operating income)",
+		})
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	type listResult struct {
+		Status  bool
+		Message string
+		Data    []map[string]string
+		Meta    financial.ListMeta
+	}
+	list := func(path string, query url.Values) (int, listResult) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path+"?"+query.Encode(), nil)
+		engine.ServeHTTP(res, req)
+		result := listResult{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result
+	}
+
+	//the cursor still works when the sort field is not returned
+	code, result := list("/api/v1/financial", url.Values{"fields": {"period,seriesReference"}, "sort": {"-dataValue"}, "pageSize": {"2"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, result.Data, []map[string]string{
+		{"seriesReference": "A", "period": "2016.06"},
+		{"seriesReference": "B", "period": "2016.06"},
+	})
+	assert.NotEmpty(t, result.Meta.NextCursor)
+	code, result = list("/api/v1/financial", url.Values{"fields": {"period,seriesReference"}, "cursor": {result.Meta.NextCursor}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, result.Data, []map[string]string{{"seriesReference": "C", "period": "2016.06"}})
+
+	code, result = list("/api/v1/financial", url.Values{"fields": {"id,dataValue"}, "scaled": {"true"}, "ids": {ids[2]}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, result.Data, []map[string]string{{
+		"id":             ids[2],
+		"dataValue":      "1",
+		"scaledValue":    "1000000",
+		"scaledUnits":    "Dollars",
+		"formattedValue": "$1m",
+	}})
+
+	code, result = list("/api/v1/financial/search", url.Values{"q": {"sales"}, "fields": {"seriesReference"}, "pageSize": {"10"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(result.Data), 3)
+	assert.Equal(t, len(result.Data[0]), 1)
+
+	code, _ = list("/api/v1/financial", url.Values{"fields": {"period,unknown"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	code, _ = list("/api/v1/financial/search", url.Values{"q": {"sales"}, "fields": {"unknown"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestRestRoutes(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	type singleResult struct {
+		Status  bool
+		Message string
+		Data    financial.SingleFinancialDataResult
+	}
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		engine.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send(http.MethodPost, "/api/v1/financial", `{
+		"seriesReference":"newSr",
+		"period":"2016.06",
+		"dataValue":"1116.386",
+		"units":"Dollars",
+		"magnitude":"6",
+		"seriesTitle1":"newSeriesTitle1",
+		"seriesTitle2":"newSeriesTitle2"
+	}`)
+	assert.Equal(t, res.Code, http.StatusCreated)
+	created := struct {
+		Status  bool
+		Message string
+		Data    map[string]string
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &created)
+	assert.Nil(t, err)
+	id := created.Data["id"]
+	location := res.Header().Get("Location")
+	assert.Equal(t, location, "/api/v1/financial/"+id)
+
+	res = send(http.MethodGet, location, "")
+	assert.Equal(t, res.Code, http.StatusOK)
+
+	//the id of the path wins over one in the body
+	res = send(http.MethodPatch, location, fmt.Sprintf(`{"id":"%s","dataValue":"1070.874"}`, primitive.NewObjectID().Hex()))
+	assert.Equal(t, res.Code, http.StatusOK)
+	single := singleResult{}
+	err = json.Unmarshal(res.Body.Bytes(), &single)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Data.ID, id)
+	assert.Equal(t, single.Data.DataValue, "1070.874")
+	assert.Equal(t, single.Data.SeriesTitle2, "newSeriesTitle2")
+
+	//fields left out of a put are cleared
+	res = send(http.MethodPut, location, `{
+		"seriesReference":"newSr",
+		"period":"2016.09",
+		"dataValue":"10",
+		"magnitude":"3",
+		"seriesTitle1":"replacedSeriesTitle1"
+	}`)
+	assert.Equal(t, res.Code, http.StatusOK)
+	single = singleResult{}
+	err = json.Unmarshal(res.Body.Bytes(), &single)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Data.ID, id)
+	assert.Equal(t, single.Data.Period, "2016.09")
+	assert.Equal(t, single.Data.Magnitude, "3")
+	assert.Equal(t, single.Data.SeriesTitle1, "replacedSeriesTitle1")
+	assert.Equal(t, single.Data.SeriesTitle2, "")
+	assert.Equal(t, single.Data.Units, "")
+
+	res = send(http.MethodDelete, location, "")
+	assert.Equal(t, res.Code, http.StatusNoContent)
+	assert.Empty(t, res.Body.String())
+	res = send(http.MethodGet, location, "")
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	res = send(http.MethodDelete, location, "")
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	res = send(http.MethodPatch, location, `{"dataValue":"1"}`)
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	res = send(http.MethodPut, location, `{"seriesReference":"newSr","period":"2016.09","magnitude":"3"}`)
+	assert.Equal(t, res.Code, http.StatusNotFound)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		res = send(method, "/api/v1/financial/invalid", `{"seriesReference":"newSr","period":"2016.09"}`)
+		assert.Equal(t, res.Code, http.StatusBadRequest, method)
+	}
+	//the legacy routes tell malformed ids apart too
+	res = send(http.MethodPost, "/api/v1/financial/delete", `{"id":"invalid"}`)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+	res = send(http.MethodPost, "/api/v1/financial/update", `{"id":"invalid","dataValue":"1"}`)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+}
+
+func TestBulk(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	existingID, err := financialService.CreateFinancialData(ctx, financial.FinancialModel{
+		SeriesReference: "sr1",
+		Period:          financial.Period{Year: 2016, Quarter: 2},
+		DataValue:       decimal(t, "1"),
+		Magnitude:       0,
+	})
+	assert.Nil(t, err)
+	missingID := primitive.NewObjectID().Hex()
+
+	type bulkResult struct {
+		Status  bool
+		Message string
+		Data    []financial.BulkOperationResult
+		Meta    financial.BulkMeta
+	}
+	bulk := func(body string) (int, bulkResult) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/financial/bulk", strings.NewReader(body))
+		engine.ServeHTTP(res, req)
+		result := bulkResult{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return res.Code, result
+	}
+	operations := fmt.Sprintf(`[
+		{"create":{"seriesReference":"sr2","period":"2016.06","dataValue":"2","magnitude":"0"}},
+		{"update":{"id":"%s","dataValue":"3"}},
+		{"delete":{"id":"%s"}},
+		{"create":{"seriesReference":"sr3","period":"2016.06","dataValue":"4","magnitude":"0"}}
+	]`, existingID, missingID)
+
+	//ordered is the default, the operations after the failed delete are skipped
+	code, result := bulk(fmt.Sprintf(`{"operations":%s}`, operations))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(result.Data), 4)
+	assert.Equal(t, result.Data[0].Status, http.StatusCreated)
+	assert.NotEmpty(t, result.Data[0].ID)
+	assert.Equal(t, result.Data[1].Status, http.StatusOK)
+	assert.Equal(t, result.Data[1].ID, existingID)
+	assert.Equal(t, result.Data[2].Status, http.StatusNotFound)
+	assert.Equal(t, result.Data[2].ID, missingID)
+	assert.Equal(t, result.Data[2].Error, "not found")
+	assert.Equal(t, result.Data[3].Status, http.StatusFailedDependency)
+	assert.Equal(t, result.Meta, financial.BulkMeta{Succeeded: 2, Failed: 1, Skipped: 1})
+	count, err := coll.CountDocuments(ctx, bson.M{"seriesReference": "sr3"})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(0))
+
+	//unordered runs every operation, the create of sr2 is now a duplicate
+	code, result = bulk(fmt.Sprintf(`{"ordered":false,"operations":%s}`, operations))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, result.Data[0].Status, http.StatusConflict)
+	assert.Equal(t, result.Data[1].Status, http.StatusOK)
+	assert.Equal(t, result.Data[2].Status, http.StatusNotFound)
+	assert.Equal(t, result.Data[3].Status, http.StatusCreated)
+	assert.Equal(t, result.Meta, financial.BulkMeta{Succeeded: 2, Failed: 2, Skipped: 0})
+
+	//an operation must be exactly one of create, update or delete
+	code, result = bulk(fmt.Sprintf(`{"operations":[{"delete":{"id":"%s"},"update":{"id":"%s"}}]}`, existingID, existingID))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, result.Data[0].Status, http.StatusBadRequest)
+	count, err = coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(3))
+
+	//invalid operations reject the whole request, before any is run
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/financial/bulk", strings.NewReader(`{"operations":[
+		{"delete":{"id":"`+existingID+`"}},
+		{"create":{"seriesReference":"sr4","period":"2016.13","magnitude":"0"}}
+	]}`))
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+	invalid := struct {
+		Status  bool
+		Message string
+		Data    map[string]string
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &invalid)
+	assert.Nil(t, err)
+	assert.Equal(t, invalid.Data, map[string]string{"operations[1].create.period": "must be a period like 2016.06"})
+	count, err = coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(3))
+
+	//ifMatch is the If-Match of an operation, the record is at revision 3
+	//after the two updates above
+	code, result = bulk(fmt.Sprintf(`{"ordered":false,"operations":[
+		{"update":{"id":"%s","dataValue":"5"},"ifMatch":1},
+		{"update":{"id":"%s","dataValue":"6"},"ifMatch":3},
+		{"delete":{"id":"%s"},"ifMatch":3},
+		{"create":{"seriesReference":"sr4","period":"2016.06","magnitude":"0"},"ifMatch":1}
+	]}`, existingID, existingID, existingID))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, result.Data[0].Status, http.StatusPreconditionFailed)
+	assert.Equal(t, result.Data[1].Status, http.StatusOK)
+	assert.Equal(t, result.Data[1].Revision, int64(4))
+	assert.Equal(t, result.Data[2].Status, http.StatusPreconditionFailed)
+	assert.Equal(t, result.Data[3].Status, http.StatusBadRequest)
+}
+
+func TestRevisions(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	send := func(method, url, ifMatch, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		engine.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send(http.MethodPost, "/api/v1/financial", "", `{"seriesReference":"sr1","period":"2016.06","magnitude":"0"}`)
+	assert.Equal(t, res.Code, http.StatusCreated)
+	location := res.Header().Get("Location")
+	res = send(http.MethodGet, location, "", "")
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("ETag"), `"1"`)
+
+	res = send(http.MethodPatch, location, `"1"`, `{"dataValue":"1"}`)
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("ETag"), `"2"`)
+	single := struct {
+		Data financial.SingleFinancialDataResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &single)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Data.Revision, int64(2))
+
+	//a stale revision changes nothing
+	res = send(http.MethodPatch, location, `"1"`, `{"dataValue":"2"}`)
+	assert.Equal(t, res.Code, http.StatusPreconditionFailed)
+	res = send(http.MethodPut, location, `"1"`, `{"seriesReference":"sr1","period":"2016.06","magnitude":"0"}`)
+	assert.Equal(t, res.Code, http.StatusPreconditionFailed)
+	res = send(http.MethodDelete, location, `"1"`, "")
+	assert.Equal(t, res.Code, http.StatusPreconditionFailed)
+	//weak tags never match
+	res = send(http.MethodPatch, location, `W/"2"`, `{"dataValue":"2"}`)
+	assert.Equal(t, res.Code, http.StatusPreconditionFailed)
+	res = send(http.MethodGet, location, "", "")
+	assert.Equal(t, res.Header().Get("ETag"), `"2"`)
+
+	//any listed revision matches, and * or no header skips the check
+	res = send(http.MethodPut, location, `"1", "2"`, `{"seriesReference":"sr1","period":"2016.06","dataValue":"3","magnitude":"0"}`)
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("ETag"), `"3"`)
+	res = send(http.MethodPatch, location, "*", `{"dataValue":"4"}`)
+	assert.Equal(t, res.Header().Get("ETag"), `"4"`)
+	res = send(http.MethodPatch, location, "", `{"dataValue":"5"}`)
+	assert.Equal(t, res.Header().Get("ETag"), `"5"`)
+
+	//of two writes of the same revision only one wins
+	codes := make([]int, 2)
+	wg := sync.WaitGroup{}
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = send(http.MethodPatch, location, `"5"`, fmt.Sprintf(`{"dataValue":"%d"}`, i)).Code
+		}(i)
+	}
+	wg.Wait()
+	assert.ElementsMatch(t, codes, []int{http.StatusOK, http.StatusPreconditionFailed})
+
+	//the legacy routes honor If-Match too
+	id := strings.TrimPrefix(location, "/api/v1/financial/")
+	res = send(http.MethodPost, "/api/v1/financial/delete", `"5"`, fmt.Sprintf(`{"id":"%s"}`, id))
+	assert.Equal(t, res.Code, http.StatusPreconditionFailed)
+	res = send(http.MethodDelete, location, `"6"`, "")
+	assert.Equal(t, res.Code, http.StatusNoContent)
+	res = send(http.MethodDelete, location, `"6"`, "")
+	assert.Equal(t, res.Code, http.StatusNotFound)
+
+	//records stored before revisions were kept are at revision 0
+	legacyID := primitive.NewObjectID()
+	_, err = coll.InsertOne(ctx, bson.M{
+		"_id":             legacyID,
+		"seriesReference": "sr2",
+		"period":          financial.Period{Year: 2016, Quarter: 2},
+		"magnitude":       0,
+	})
+	assert.Nil(t, err)
+	legacyLocation := "/api/v1/financial/" + legacyID.Hex()
+	res = send(http.MethodGet, legacyLocation, "", "")
+	assert.Equal(t, res.Header().Get("ETag"), `"0"`)
+	res = send(http.MethodPatch, legacyLocation, `"0"`, `{"dataValue":"1"}`)
+	assert.Equal(t, res.Code, http.StatusOK)
+	assert.Equal(t, res.Header().Get("ETag"), `"1"`)
+
+	//imports bump the revision of the records they overwrite
+	err = financialService.UpsertFinancialData(ctx, financial.FinancialModel{
+		SeriesReference: "sr2",
+		Period:          financial.Period{Year: 2016, Quarter: 2},
+	})
+	assert.Nil(t, err)
+	res = send(http.MethodGet, legacyLocation, "", "")
+	assert.Equal(t, res.Header().Get("ETag"), `"2"`)
+}
+
+func TestSoftDelete(t *testing.T) {
+	container := di.NewContainer()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	cfg := container.GetCfg()
+	dbName := cfg.GetString("mongodb.dbname")
+	mongoDBClient, err := container.GetMongoDBClient()
+	assert.Nil(t, err)
+	ctx := context.Background()
+	coll := mongoDBClient.Database(dbName).Collection("financialData")
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+
+	financialService := container.GetFinancialService()
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	send := func(method, url string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, nil)
+		engine.ServeHTTP(res, req)
+		return res
+	}
+	type listResult struct {
+		Status  bool
+		Message string
+		Data    []financial.SingleFinancialDataResult
+		Meta    financial.ListMeta
+	}
+	list := func(url string) listResult {
+		res := send(http.MethodGet, url)
+		assert.Equal(t, res.Code, http.StatusOK)
+		result := listResult{}
+		err := json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		return result
+	}
+
+	ids := make([]string, 3)
+	for i := range ids {
+		ids[i], err = financialService.CreateFinancialData(ctx, financial.FinancialModel{
+			SeriesReference: fmt.Sprintf("sr%d", i+1),
+			Period:          financial.Period{Year: 2016, Quarter: 2},
+			DataValue:       decimal(t, "1"),
+			Subject:         "subject",
+		})
+		assert.Nil(t, err)
+	}
+	res := send(http.MethodDelete, "/api/v1/financial/"+ids[0])
+	assert.Equal(t, res.Code, http.StatusNoContent)
+	res = send(http.MethodDelete, "/api/v1/financial/"+ids[1])
+	assert.Equal(t, res.Code, http.StatusNoContent)
+
+	//deleted records are hidden from every read and write
+	res = send(http.MethodGet, "/api/v1/financial/"+ids[0])
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	res = send(http.MethodDelete, "/api/v1/financial/"+ids[0])
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	result := list("/api/v1/financial?withTotal=true")
+	assert.Equal(t, len(result.Data), 1)
+	assert.Equal(t, result.Data[0].ID, ids[2])
+	assert.Equal(t, *result.Meta.Total, int64(1))
+	result = list("/api/v1/financial?ids=" + ids[2])
+	assert.Equal(t, len(result.Data), 1)
+	res = send(http.MethodGet, "/api/v1/financial?ids="+ids[0])
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	res = send(http.MethodGet, "/api/v1/financial/facets")
+	assert.Contains(t, res.Body.String(), `"subject":[{"value":"subject","count":1}]`)
+
+	//the latest deleted comes first
+	result = list("/api/v1/financial/deleted")
+	assert.Equal(t, len(result.Data), 2)
+	assert.Equal(t, result.Data[0].ID, ids[1])
+	assert.Equal(t, result.Data[1].ID, ids[0])
+	assert.NotEmpty(t, result.Data[0].DeletedAt)
+	assert.Equal(t, *result.Meta.Total, int64(2))
+
+	res = send(http.MethodPost, "/api/v1/financial/"+ids[0]+"/restore")
+	assert.Equal(t, res.Code, http.StatusOK)
+	single := struct {
+		Data financial.SingleFinancialDataResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &single)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Data.ID, ids[0])
+	assert.Empty(t, single.Data.DeletedAt)
+	assert.Equal(t, res.Header().Get("ETag"), `"3"`)
+	res = send(http.MethodPost, "/api/v1/financial/"+ids[0]+"/restore")
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	res = send(http.MethodGet, "/api/v1/financial/"+ids[0])
+	assert.Equal(t, res.Code, http.StatusOK)
+
+	//only the records deleted before the cutoff are purged
+	res = send(http.MethodDelete, "/api/v1/financial/"+ids[2])
+	assert.Equal(t, res.Code, http.StatusNoContent)
+	objectID, err := primitive.ObjectIDFromHex(ids[1])
+	assert.Nil(t, err)
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"deletedAt": time.Now().Add(-48 * time.Hour)}})
+	assert.Nil(t, err)
+	purged, err := financialService.PurgeDeletedFinancialData(ctx, time.Now().Add(-24*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, purged, int64(1))
+	err = coll.FindOne(ctx, bson.M{"_id": objectID}).Err()
+	assert.Equal(t, err, mongo.ErrNoDocuments)
+	result = list("/api/v1/financial/deleted")
+	assert.Equal(t, len(result.Data), 1)
+	assert.Equal(t, result.Data[0].ID, ids[2])
+
+	//creating a deleted record again points to the one to restore
+	res = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/financial", strings.NewReader(
+		`{"seriesReference":"sr3","period":"2016.06","magnitude":"0"}`,
+	))
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusConflict)
+	conflict := struct {
+		Status  bool
+		Message string
+		Data    map[string]string
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &conflict)
+	assert.Nil(t, err)
+	assert.Equal(t, conflict.Message, financial.ErrDeletedFinancialData.Error())
+	assert.Equal(t, conflict.Data["id"], ids[2])
+
+	//importing a deleted record leaves it deleted
+	err = financialService.UpsertFinancialData(ctx, financial.FinancialModel{
+		SeriesReference: "sr3",
+		Period:          financial.Period{Year: 2016, Quarter: 2},
+	})
+	assert.ErrorIs(t, err, financial.ErrDeletedFinancialData)
+	failed, err := financialService.UpsertManyFinancialData(ctx, []financial.FinancialModel{
+		{SeriesReference: "sr3", Period: financial.Period{Year: 2016, Quarter: 2}},
+		{SeriesReference: "sr4", Period: financial.Period{Year: 2016, Quarter: 2}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, len(failed), 1)
+	assert.ErrorIs(t, failed[0], financial.ErrDeletedFinancialData)
+	res = send(http.MethodGet, "/api/v1/financial/"+ids[2])
+	assert.Equal(t, res.Code, http.StatusNotFound)
+	result = list("/api/v1/financial/deleted")
+	assert.Equal(t, len(result.Data), 1)
+	assert.Equal(t, result.Data[0].ID, ids[2])
+	assert.Equal(t, result.Data[0].Revision, int64(2))
+	result = list("/api/v1/financial?seriesReference=sr4")
+	assert.Equal(t, len(result.Data), 1)
 }
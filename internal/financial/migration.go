@@ -0,0 +1,164 @@
+package financial
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// quarantineCollectionName holds the documents the migration could not
+// convert, out of the way of the queries that decode financial data.
+const quarantineCollectionName = "financialDataQuarantine"
+
+// legacyTypesFilter matches documents written before period, dataValue and
+// magnitude were stored with their own types.
+var legacyTypesFilter = bson.M{
+	"$or": bson.A{
+		bson.M{"period": bson.M{"$type": "string"}},
+		bson.M{"dataValue": bson.M{"$type": "string"}},
+		bson.M{"magnitude": bson.M{"$type": "string"}},
+	},
+}
+
+// MigrationFailure is a document that could not be converted. It was moved
+// to the quarantine collection.
+type MigrationFailure struct {
+	ID  string
+	Err error
+}
+
+// quarantinedModel is a document the migration could not convert, kept as it
+// was stored along with the reason.
+type quarantinedModel struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Document      bson.M             `bson:"document"`
+	Error         string             `bson:"error"`
+	QuarantinedAt time.Time          `bson:"quarantinedAt"`
+}
+
+// MigrateLegacyTypes converts documents that still hold string period,
+// dataValue and magnitude fields to the typed representation, and renames
+// the Subject key the old update wrote to subject. Documents that cannot be
+// converted are moved to the quarantine collection and reported back as
+// failures, as no query could decode them.
+func (r *Repository) MigrateLegacyTypes(ctx context.Context) (migrated int, failures []MigrationFailure, err error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	//the old update set Subject, so it is newer than subject
+	_, err = coll.UpdateMany(ctx, bson.M{"Subject": bson.M{"$exists": true}}, bson.M{"$rename": bson.M{"Subject": "subject"}})
+	if err != nil {
+		return 0, nil, err
+	}
+	cursor, err := coll.Find(ctx, legacyTypesFilter)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		doc := bson.M{}
+		err = cursor.Decode(&doc)
+		if err != nil {
+			return migrated, failures, err
+		}
+		id, _ := doc["_id"].(primitive.ObjectID)
+		set, err := convertLegacyTypes(doc)
+		if err != nil {
+			qErr := r.quarantine(ctx, id, doc, err)
+			if qErr != nil {
+				return migrated, failures, qErr
+			}
+			failures = append(failures, MigrationFailure{ID: id.Hex(), Err: err})
+			continue
+		}
+		_, err = coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+		if err != nil {
+			return migrated, failures, err
+		}
+		migrated++
+	}
+	return migrated, failures, cursor.Err()
+}
+
+// quarantine moves the document id to the quarantine collection. It is
+// written there before it is deleted, so a migration stopped in between
+// only writes it again.
+func (r *Repository) quarantine(ctx context.Context, id primitive.ObjectID, doc bson.M, reason error) error {
+	db := r.mongoDBClient.Database(r.dbName)
+	q := quarantinedModel{ID: id, Document: doc, Error: reason.Error(), QuarantinedAt: time.Now()}
+	_, err := db.Collection(quarantineCollectionName).ReplaceOne(ctx, bson.M{"_id": id}, q, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection(financialDataCollectionName).DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func convertLegacyTypes(doc bson.M) (bson.M, error) {
+	set := bson.M{}
+	if v, ok := doc["period"].(string); ok {
+		p, err := ParsePeriod(v)
+		if err != nil {
+			return nil, err
+		}
+		set["period"] = p
+	}
+	if v, ok := doc["dataValue"].(string); ok {
+		d, err := ParseDataValue(v)
+		if err != nil {
+			return nil, err
+		}
+		set["dataValue"] = d
+	}
+	if v, ok := doc["magnitude"].(string); ok {
+		m, err := ParseMagnitude(v)
+		if err != nil {
+			return nil, err
+		}
+		set["magnitude"] = m
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("nothing to convert")
+	}
+	return set, nil
+}
+
+// RemoveDuplicates keeps the oldest document of every seriesReference and
+// period pair and deletes the rest. Earlier restarts of the csv import
+// inserted the same rows again, which would make the unique index fail.
+func (r *Repository) RemoveDuplicates(ctx context.Context) (int64, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"seriesReference": "$seriesReference", "period": "$period"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	var removed int64
+	for cursor.Next(ctx) {
+		group := struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}{}
+		err = cursor.Decode(&group)
+		if err != nil {
+			return removed, err
+		}
+		res, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}})
+		if err != nil {
+			return removed, err
+		}
+		removed += res.DeletedCount
+	}
+	return removed, cursor.Err()
+}
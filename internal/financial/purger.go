@@ -0,0 +1,65 @@
+package financial
+
+import (
+	"context"
+	"time"
+	"we-connect-test/config"
+
+	"go.uber.org/zap"
+)
+
+const defaultPurgeInterval = time.Hour
+
+// Purger removes the financial data deleted more than a retention period ago
+// for good, so deletes can be restored for that long.
+type Purger struct {
+	service   *Service
+	logger    *zap.Logger
+	retention time.Duration
+	interval  time.Duration
+}
+
+// Start purges every interval until ctx is done.
+func (p *Purger) Start(ctx context.Context) {
+	p.logger.Info("purging deleted financial data", zap.Duration("retention", p.retention))
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	purged, err := p.service.PurgeDeletedFinancialData(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		p.logger.Error("cannot purge deleted financial data", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		p.logger.Info("purged deleted financial data", zap.Int64("purged", purged))
+	}
+}
+
+// NewPurger returns the purger of financial.purge.afterDays, or nil when it
+// is not set and deleted data is kept.
+func NewPurger(cfg *config.Cfg, service *Service, logger *zap.Logger) *Purger {
+	afterDays := cfg.GetInt("financial.purge.afterDays")
+	if afterDays <= 0 {
+		return nil
+	}
+	interval := cfg.GetDuration("financial.purge.interval")
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+	return &Purger{
+		service:   service,
+		logger:    logger,
+		retention: time.Duration(afterDays) * 24 * time.Hour,
+		interval:  interval,
+	}
+}
@@ -2,6 +2,8 @@ package financial
 
 import (
 	"context"
+	"errors"
+	"time"
 	"we-connect-test/config"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,34 +14,51 @@ import (
 
 const (
 	financialDataCollectionName = "financialData"
+	naturalKeyIndexName         = "seriesReference_period_unique"
+	searchIndexName             = "titles_subject_group_text"
 )
 
+var ErrDuplicateFinancialData = errors.New("financial data with the same seriesReference and period already exists")
+
+var ErrRevisionMismatch = errors.New("financial data was changed since it was read")
+
+// ErrDeletedFinancialData is a duplicate of a deleted record, which keeps its
+// seriesReference and period until it is purged.
+var ErrDeletedFinancialData = errors.New("deleted financial data with the same seriesReference and period exists, restore it instead")
+
 type FinancialModel struct {
-	ID              primitive.ObjectID `bson:"_id"`
-	SeriesReference string             `bson:"seriesReference"`
-	Period          string             `bson:"period"`
-	DataValue       string             `bson:"dataValue"`
-	Suppressed      string             `bson:"suppressed"`
-	Status          string             `bson:"status"`
-	Units           string             `bson:"units"`
-	Magnitude       string             `bson:"magnitude"`
-	Subject         string             `bson:"subject"`
-	Group           string             `bson:"group"`
-	SeriesTitle1    string             `bson:"seriesTitle1"`
-	SeriesTitle2    string             `bson:"seriesTitle2"`
-	SeriesTitle3    string             `bson:"seriesTitle3"`
-	SeriesTitle4    string             `bson:"seriesTitle4"`
-	SeriesTitle5    string             `bson:"seriesTitle5"`
+	ID              primitive.ObjectID    `bson:"_id"`
+	SeriesReference string                `bson:"seriesReference"`
+	Period          Period                `bson:"period"`
+	DataValue       *primitive.Decimal128 `bson:"dataValue"`
+	Suppressed      string                `bson:"suppressed"`
+	Status          string                `bson:"status"`
+	Units           string                `bson:"units"`
+	Magnitude       int                   `bson:"magnitude"`
+	Subject         string                `bson:"subject"`
+	Group           string                `bson:"group"`
+	SeriesTitle1    string                `bson:"seriesTitle1"`
+	SeriesTitle2    string                `bson:"seriesTitle2"`
+	SeriesTitle3    string                `bson:"seriesTitle3"`
+	SeriesTitle4    string                `bson:"seriesTitle4"`
+	SeriesTitle5    string                `bson:"seriesTitle5"`
+	// Revision counts the writes of the document. It is set by the
+	// repository, documents stored before it was kept are at 0.
+	Revision int64 `bson:"revision"`
+	// DeletedAt is set on deleted documents, which are hidden from every
+	// read until they are restored or purged.
+	DeletedAt *time.Time `bson:"deletedAt,omitempty"`
 }
 
 type FinancialUpdateModel struct {
 	SeriesReference *string
-	Period          *string
-	DataValue       *string
+	Period          *Period
+	DataValue       *primitive.Decimal128
+	ClearDataValue  bool
 	Suppressed      *string
 	Status          *string
 	Units           *string
-	Magnitude       *string
+	Magnitude       *int
 	Subject         *string
 	Group           *string
 	SeriesTitle1    *string
@@ -49,17 +68,184 @@ type FinancialUpdateModel struct {
 	SeriesTitle5    *string
 }
 
+// FinancialFilter narrows a list query. Empty fields are not filtered on and
+// the period range is inclusive on both ends.
+type FinancialFilter struct {
+	SeriesReference string
+	PeriodFrom      *Period
+	PeriodTo        *Period
+	Status          string
+	Units           string
+	Subject         string
+	Group           string
+	SeriesTitle1    string
+	SeriesTitle2    string
+	SeriesTitle3    string
+	SeriesTitle4    string
+	SeriesTitle5    string
+
+	// SeriesReferences matches any of the listed series, it is used instead
+	// of SeriesReference when set.
+	SeriesReferences []string
+}
+
+func (f FinancialFilter) toBSON() bson.M {
+	filter := bson.M{}
+	fields := map[string]string{
+		"seriesReference": f.SeriesReference,
+		"status":          f.Status,
+		"units":           f.Units,
+		"subject":         f.Subject,
+		"group":           f.Group,
+		"seriesTitle1":    f.SeriesTitle1,
+		"seriesTitle2":    f.SeriesTitle2,
+		"seriesTitle3":    f.SeriesTitle3,
+		"seriesTitle4":    f.SeriesTitle4,
+		"seriesTitle5":    f.SeriesTitle5,
+	}
+	for field, value := range fields {
+		if value != "" {
+			filter[field] = value
+		}
+	}
+	if len(f.SeriesReferences) > 0 {
+		filter["seriesReference"] = bson.M{"$in": f.SeriesReferences}
+	}
+	//periods are {year, quarter} documents, mongo compares them field by
+	//field in that order, so a range on the whole document is chronological
+	period := bson.M{}
+	if f.PeriodFrom != nil {
+		period["$gte"] = f.PeriodFrom
+	}
+	if f.PeriodTo != nil {
+		period["$lte"] = f.PeriodTo
+	}
+	if len(period) > 0 {
+		filter["period"] = period
+	}
+	filter["deletedAt"] = nil
+	return filter
+}
+
+// SeriesModel is one time series, the financial data sharing a
+// seriesReference. Descriptive fields are taken from its latest observation.
+type SeriesModel struct {
+	SeriesReference  string `bson:"_id"`
+	Units            string `bson:"units"`
+	Magnitude        int    `bson:"magnitude"`
+	Subject          string `bson:"subject"`
+	Group            string `bson:"group"`
+	SeriesTitle1     string `bson:"seriesTitle1"`
+	SeriesTitle2     string `bson:"seriesTitle2"`
+	SeriesTitle3     string `bson:"seriesTitle3"`
+	SeriesTitle4     string `bson:"seriesTitle4"`
+	SeriesTitle5     string `bson:"seriesTitle5"`
+	ObservationCount int    `bson:"observationCount"`
+	FirstPeriod      Period `bson:"firstPeriod"`
+	LastPeriod       Period `bson:"lastPeriod"`
+}
+
+// AggregationModel holds the statistics of the data values of one group.
+// Records without a data value are not counted, Sum, Avg, Min and Max are
+// nil when no record of the group has one.
+type AggregationModel struct {
+	Key   bson.RawValue         `bson:"_id"`
+	Count int                   `bson:"count"`
+	Sum   *primitive.Decimal128 `bson:"sum"`
+	Avg   *primitive.Decimal128 `bson:"avg"`
+	Min   *primitive.Decimal128 `bson:"min"`
+	Max   *primitive.Decimal128 `bson:"max"`
+}
+
+// facetFields are the fields distinct values are counted for.
+var facetFields = []string{
+	"status",
+	"units",
+	"subject",
+	"group",
+	"seriesTitle1",
+	"seriesTitle2",
+	"seriesTitle3",
+	"seriesTitle4",
+	"seriesTitle5",
+}
+
+type FacetValueModel struct {
+	Value string `bson:"_id"`
+	Count int64  `bson:"count"`
+}
+
 type Repository struct {
 	dbName        string
 	mongoDBClient *mongo.Client
 }
 
-func (r *Repository) GetFinancialDataByPagination(ctx context.Context, page, pageSize int) ([]FinancialModel, error) {
+// FinancialListQuery selects a page of a sorted list. Page based lists set
+// Skip, cursor based lists set After.
+type FinancialListQuery struct {
+	Filter FinancialFilter
+	Sort   FinancialSort
+	Skip   int
+	After  *listCursor
+	Limit  int
+	Fields FinancialFields
+}
+
+// GetFinancialDataList returns the page of q in sort order. hasMore reports
+// whether there are more models past the page in the direction it was read,
+// towards the start of the list for a backward cursor.
+func (r *Repository) GetFinancialDataList(ctx context.Context, q FinancialListQuery) (models []FinancialModel, hasMore bool, err error) {
+	backward := q.After != nil && q.After.Backward
+	filter := q.Filter.toBSON()
+	if q.After != nil {
+		filter = bson.M{"$and": bson.A{filter, q.After.filter()}}
+	}
+	//one extra model tells whether there is a next page
+	opts := options.Find().
+		SetSort(q.Sort.toBSON(backward)).
+		SetLimit(int64(q.Limit + 1)).
+		SetSkip(int64(q.Skip)).
+		SetAllowDiskUse(true)
+	//cursors are made from the sort field of the models
+	if projection := q.Fields.toBSON(q.Sort.Field); projection != nil {
+		opts.SetProjection(projection)
+	}
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	var results []FinancialModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+		hasMore = true
+	}
+	if backward {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
+	return results, hasMore, nil
+}
+
+// SearchFinancialData returns the models of filter matching text in the text
+// index, ordered by relevance.
+func (r *Repository) SearchFinancialData(ctx context.Context, text string, filter FinancialFilter, fields FinancialFields, skip, limit int) ([]FinancialModel, error) {
+	query := filter.toBSON()
+	query["$text"] = bson.M{"$search": text}
 	opts := options.Find().
-		SetLimit(int64(pageSize)).
-		SetSkip(int64(page * pageSize))
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+	if projection := fields.toBSON(); projection != nil {
+		opts.SetProjection(projection)
+	}
 	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
-	cursor, err := coll.Find(ctx, bson.M{}, opts)
+	cursor, err := coll.Find(ctx, query, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -71,10 +257,22 @@ func (r *Repository) GetFinancialDataByPagination(ctx context.Context, page, pag
 	return results, nil
 }
 
+func (r *Repository) CountFinancialData(ctx context.Context, filter FinancialFilter) (int64, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	return coll.CountDocuments(ctx, filter.toBSON())
+}
+
+// CreateFinancialData inserts m and returns its id. When a deleted document
+// holds the seriesReference and period of m, its id is returned with
+// ErrDeletedFinancialData.
 func (r *Repository) CreateFinancialData(ctx context.Context, m FinancialModel) (string, error) {
 	m.ID = primitive.NewObjectID()
+	m.Revision = 1
 	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
 	result, err := coll.InsertOne(ctx, m)
+	if mongo.IsDuplicateKeyError(err) {
+		return r.duplicateError(ctx, m)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -82,13 +280,29 @@ func (r *Repository) CreateFinancialData(ctx context.Context, m FinancialModel)
 	return id.Hex(), nil
 }
 
+// duplicateError tells whether the document holding the seriesReference and
+// period of m is deleted, returning its id when it is.
+func (r *Repository) duplicateError(ctx context.Context, m FinancialModel) (string, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	filter := bson.M{"seriesReference": m.SeriesReference, "period": m.Period, "deletedAt": bson.M{"$ne": nil}}
+	deleted := FinancialModel{}
+	err := coll.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&deleted)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrDuplicateFinancialData
+	}
+	if err != nil {
+		return "", err
+	}
+	return deleted.ID.Hex(), ErrDeletedFinancialData
+}
+
 func (r *Repository) GetFinancialDataByID(ctx context.Context, id string) (FinancialModel, error) {
 	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return FinancialModel{}, err
 	}
-	filter := bson.D{{"_id", objectID}}
+	filter := bson.M{"_id": objectID, "deletedAt": nil}
 	res := coll.FindOne(ctx, filter)
 	if res.Err() != nil {
 		return FinancialModel{}, res.Err()
@@ -98,13 +312,35 @@ func (r *Repository) GetFinancialDataByID(ctx context.Context, id string) (Finan
 	return m, err
 }
 
-func (r *Repository) UpdateFinancialData(ctx context.Context, id string, m FinancialUpdateModel) error {
+// GetFinancialDataByIDs returns the models of ids that exist, in no
+// particular order.
+func (r *Repository) GetFinancialDataByIDs(ctx context.Context, ids []primitive.ObjectID, fields FinancialFields) ([]FinancialModel, error) {
+	opts := options.Find()
+	if projection := fields.toBSON(); projection != nil {
+		opts.SetProjection(projection)
+	}
 	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "deletedAt": nil}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var results []FinancialModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// UpdateFinancialData sets the fields of m on the document with id and
+// returns it as written. When revisions are given the document is only
+// updated while it is at one of them, otherwise ErrRevisionMismatch is
+// returned. It returns mongo.ErrNoDocuments when there is no such document.
+func (r *Repository) UpdateFinancialData(ctx context.Context, id string, m FinancialUpdateModel, revisions []int64) (FinancialModel, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return err
+		return FinancialModel{}, err
 	}
-	filter := bson.D{{"_id", objectID}}
 	set := bson.D{}
 	if m.SeriesReference != nil {
 		set = append(set, bson.E{"seriesReference", m.SeriesReference})
@@ -112,7 +348,7 @@ func (r *Repository) UpdateFinancialData(ctx context.Context, id string, m Finan
 	if m.Period != nil {
 		set = append(set, bson.E{"period", m.Period})
 	}
-	if m.DataValue != nil {
+	if m.DataValue != nil || m.ClearDataValue {
 		set = append(set, bson.E{"dataValue", m.DataValue})
 	}
 	if m.Suppressed != nil {
@@ -128,7 +364,7 @@ func (r *Repository) UpdateFinancialData(ctx context.Context, id string, m Finan
 		set = append(set, bson.E{"magnitude", m.Magnitude})
 	}
 	if m.Subject != nil {
-		set = append(set, bson.E{"Subject", m.Subject})
+		set = append(set, bson.E{"subject", m.Subject})
 	}
 	if m.Group != nil {
 		set = append(set, bson.E{"group", m.Group})
@@ -148,20 +384,417 @@ func (r *Repository) UpdateFinancialData(ctx context.Context, id string, m Finan
 	if m.SeriesTitle5 != nil {
 		set = append(set, bson.E{"seriesTitle5", m.SeriesTitle5})
 	}
-	update := bson.D{{"$set", set}}
-	_, err = coll.UpdateOne(ctx, filter, update)
+	update := bson.M{"$inc": bson.M{"revision": 1}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	written, err := r.findOneAndUpdate(ctx, revisionFilter(objectID, revisions), update)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return FinancialModel{}, r.unmatchedError(ctx, objectID, revisions)
+	}
+	return written, err
+}
+
+// UpsertFinancialData inserts m, or overwrites the document that already
+// holds the same seriesReference and period. A deleted document is left as
+// it is and ErrDeletedFinancialData is returned, only a restore brings it
+// back.
+func (r *Repository) UpsertFinancialData(ctx context.Context, m FinancialModel) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	set, err := setDocument(m)
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpdateOne(ctx, upsertFilter(m), upsertUpdate(set), options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDeletedFinancialData
+	}
 	return err
 }
 
-func (r *Repository) DeleteFinancialData(ctx context.Context, id string) error {
+// upsertFilter matches the document of m unless it is deleted. The upsert
+// then tries to insert a new one, which the unique index rejects.
+func upsertFilter(m FinancialModel) bson.M {
+	return bson.M{"seriesReference": m.SeriesReference, "period": m.Period, "deletedAt": nil}
+}
+
+func upsertUpdate(set bson.D) bson.M {
+	return bson.M{
+		"$set":         set,
+		"$inc":         bson.M{"revision": 1},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+}
+
+// UpsertManyFinancialData upserts all models in one unordered bulk write.
+// Models that fail are returned by their index in ms, the rest are stored.
+// Models of deleted documents fail with ErrDeletedFinancialData.
+func (r *Repository) UpsertManyFinancialData(ctx context.Context, ms []FinancialModel) (map[int]error, error) {
+	failed := make(map[int]error)
+	writeModels := make([]mongo.WriteModel, 0, len(ms))
+	//positions maps an index in writeModels back to its index in ms
+	positions := make([]int, 0, len(ms))
+	for i, m := range ms {
+		set, err := setDocument(m)
+		if err != nil {
+			failed[i] = err
+			continue
+		}
+		writeModels = append(writeModels, mongo.NewUpdateOneModel().
+			SetFilter(upsertFilter(m)).
+			SetUpdate(upsertUpdate(set)).
+			SetUpsert(true))
+		positions = append(positions, i)
+	}
+	if len(writeModels) == 0 {
+		return failed, nil
+	}
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	_, err := coll.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if mongo.IsDuplicateKeyError(writeErr) {
+				failed[positions[writeErr.Index]] = ErrDeletedFinancialData
+				continue
+			}
+			failed[positions[writeErr.Index]] = writeErr
+		}
+		return failed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// EnsureIndexes creates the unique seriesReference and period index, which
+// also serves seriesReference filters, and an index for every other list
+// filter. period is the last key of each so a period range can be combined
+// with any filter. The text index backs SearchFinancialData.
+func (r *Repository) EnsureIndexes(ctx context.Context) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "seriesReference", Value: 1},
+				{Key: "period", Value: 1},
+			},
+			Options: options.Index().SetName(naturalKeyIndexName).SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "period", Value: 1}}},
+		//serves the list of deleted documents and the purge
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}},
+		{Keys: bson.D{{Key: "subject", Value: 1}, {Key: "group", Value: 1}, {Key: "period", Value: 1}}},
+		{
+			Keys: bson.D{
+				{Key: "seriesTitle1", Value: "text"},
+				{Key: "seriesTitle2", Value: "text"},
+				{Key: "seriesTitle3", Value: "text"},
+				{Key: "seriesTitle4", Value: "text"},
+				{Key: "seriesTitle5", Value: "text"},
+				{Key: "subject", Value: "text"},
+				{Key: "group", Value: "text"},
+			},
+			Options: options.Index().SetName(searchIndexName),
+		},
+	}
+	//every list filter is served by an index, seriesReference and subject by
+	//the ones above and the rest by these, with period for the range filter
+	for _, field := range []string{"status", "units", "group", "seriesTitle1", "seriesTitle2", "seriesTitle3", "seriesTitle4", "seriesTitle5"} {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys: bson.D{{Key: field, Value: 1}, {Key: "period", Value: 1}},
+		})
+	}
+	_, err := coll.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// setDocument returns every field of m except _id and revision, to be used
+// in $set. The revision is incremented instead.
+func setDocument(m FinancialModel) (bson.D, error) {
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.D{}
+	err = bson.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, err
+	}
+	set := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key != "_id" && e.Key != "revision" {
+			set = append(set, e)
+		}
+	}
+	return set, nil
+}
+
+// ReplaceFinancialData overwrites every field of the document with id by
+// the ones of m and returns it as written, with the revisions and errors of
+// UpdateFinancialData.
+func (r *Repository) ReplaceFinancialData(ctx context.Context, id string, m FinancialModel, revisions []int64) (FinancialModel, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return FinancialModel{}, err
+	}
+	set, err := setDocument(m)
+	if err != nil {
+		return FinancialModel{}, err
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"revision": 1}}
+	written, err := r.findOneAndUpdate(ctx, revisionFilter(objectID, revisions), update)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return FinancialModel{}, r.unmatchedError(ctx, objectID, revisions)
+	}
+	return written, err
+}
+
+// findOneAndUpdate applies update to the document of filter and returns the
+// document the update wrote. Reading it back separately could return the
+// revision of a later write.
+func (r *Repository) findOneAndUpdate(ctx context.Context, filter bson.M, update bson.M) (FinancialModel, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	m := FinancialModel{}
+	err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&m)
+	if mongo.IsDuplicateKeyError(err) {
+		return FinancialModel{}, ErrDuplicateFinancialData
+	}
+	if err != nil {
+		return FinancialModel{}, err
+	}
+	return m, nil
+}
+
+// DeleteFinancialData marks the document with id deleted at deletedAt, with
+// the revisions and errors of UpdateFinancialData. The document is kept
+// until it is purged, so it can be restored.
+func (r *Repository) DeleteFinancialData(ctx context.Context, id string, deletedAt time.Time, revisions []int64) error {
 	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
-	filter := bson.D{{"_id", objectID}}
-	_, err = coll.DeleteOne(ctx, filter)
-	return err
+	update := bson.M{"$set": bson.M{"deletedAt": deletedAt}, "$inc": bson.M{"revision": 1}}
+	result, err := coll.UpdateOne(ctx, revisionFilter(objectID, revisions), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return r.unmatchedError(ctx, objectID, revisions)
+	}
+	return nil
+}
+
+// GetDeletedFinancialDataList returns a page of the deleted documents, the
+// latest deleted first.
+func (r *Repository) GetDeletedFinancialDataList(ctx context.Context, skip, limit int) ([]FinancialModel, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "deletedAt", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	cursor, err := coll.Find(ctx, bson.M{"deletedAt": bson.M{"$ne": nil}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var results []FinancialModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *Repository) CountDeletedFinancialData(ctx context.Context) (int64, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	return coll.CountDocuments(ctx, bson.M{"deletedAt": bson.M{"$ne": nil}})
+}
+
+// RestoreFinancialData clears the delete of the document with id and returns
+// it as written. It returns mongo.ErrNoDocuments when there is no such
+// deleted document.
+func (r *Repository) RestoreFinancialData(ctx context.Context, id string) (FinancialModel, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return FinancialModel{}, err
+	}
+	filter := bson.M{"_id": objectID, "deletedAt": bson.M{"$ne": nil}}
+	update := bson.M{"$unset": bson.M{"deletedAt": ""}, "$inc": bson.M{"revision": 1}}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+// PurgeFinancialData removes the documents deleted at or before
+// deletedBefore for good and returns how many there were.
+func (r *Repository) PurgeFinancialData(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	result, err := coll.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$lte": deletedBefore}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// revisionFilter matches the document with id unless it is deleted, only
+// while it is at one of revisions when any are given. Checking the revision
+// in the filter of the write keeps a concurrent write from slipping in
+// between check and write.
+func revisionFilter(id primitive.ObjectID, revisions []int64) bson.M {
+	filter := bson.M{"_id": id, "deletedAt": nil}
+	if len(revisions) == 0 {
+		return filter
+	}
+	in := bson.A{}
+	for _, revision := range revisions {
+		in = append(in, revision)
+		//documents stored before revisions were kept have none
+		if revision == 0 {
+			in = append(in, nil)
+		}
+	}
+	filter["revision"] = bson.M{"$in": in}
+	return filter
+}
+
+// unmatchedError tells why a write of the document with id matched nothing,
+// ErrRevisionMismatch when it exists at another revision.
+func (r *Repository) unmatchedError(ctx context.Context, id primitive.ObjectID, revisions []int64) error {
+	if len(revisions) == 0 {
+		return mongo.ErrNoDocuments
+	}
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	count, err := coll.CountDocuments(ctx, bson.M{"_id": id, "deletedAt": nil}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return ErrRevisionMismatch
+}
+
+// GetSeriesList returns a page of the series in the data, ordered by
+// seriesReference. Filters select the observations a series is built from.
+func (r *Repository) GetSeriesList(ctx context.Context, filter FinancialFilter, skip, limit int) ([]SeriesModel, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter.toBSON()}},
+		//sorted on the unique index, so $last is the latest observation
+		{{Key: "$sort", Value: bson.D{{Key: "seriesReference", Value: 1}, {Key: "period", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":              "$seriesReference",
+			"units":            bson.M{"$last": "$units"},
+			"magnitude":        bson.M{"$last": "$magnitude"},
+			"subject":          bson.M{"$last": "$subject"},
+			"group":            bson.M{"$last": "$group"},
+			"seriesTitle1":     bson.M{"$last": "$seriesTitle1"},
+			"seriesTitle2":     bson.M{"$last": "$seriesTitle2"},
+			"seriesTitle3":     bson.M{"$last": "$seriesTitle3"},
+			"seriesTitle4":     bson.M{"$last": "$seriesTitle4"},
+			"seriesTitle5":     bson.M{"$last": "$seriesTitle5"},
+			"observationCount": bson.M{"$sum": 1},
+			"firstPeriod":      bson.M{"$first": "$period"},
+			"lastPeriod":       bson.M{"$last": "$period"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$skip", Value: skip}},
+		{{Key: "$limit", Value: limit}},
+	}
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	cursor, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	var results []SeriesModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetObservations returns the financial data of filter ordered by
+// seriesReference and period.
+func (r *Repository) GetObservations(ctx context.Context, filter FinancialFilter) ([]FinancialModel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "seriesReference", Value: 1}, {Key: "period", Value: 1}})
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	cursor, err := coll.Find(ctx, filter.toBSON(), opts)
+	if err != nil {
+		return nil, err
+	}
+	var results []FinancialModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AggregateFinancialData groups the financial data of filter on the
+// dimension expression and computes the statistics of their data values.
+func (r *Repository) AggregateFinancialData(ctx context.Context, filter FinancialFilter, dimension string) ([]AggregationModel, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter.toBSON()}},
+		{{Key: "$group", Value: bson.M{
+			"_id": dimension,
+			//null and missing values are not greater than null
+			"count": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$dataValue", nil}}, 1, 0}}},
+			"sum":   bson.M{"$sum": "$dataValue"},
+			"avg":   bson.M{"$avg": "$dataValue"},
+			"min":   bson.M{"$min": "$dataValue"},
+			"max":   bson.M{"$max": "$dataValue"},
+		}}},
+		//$sum gives an integer 0 for a group without values
+		{{Key: "$set", Value: bson.M{
+			"sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$count", 0}}, nil, bson.M{"$toDecimal": "$sum"}}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	cursor, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	var results []AggregationModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetFinancialFacets counts the financial data of filter per distinct value
+// of every facet field, in one query. Values are ordered by count, the most
+// common first.
+func (r *Repository) GetFinancialFacets(ctx context.Context, filter FinancialFilter) (map[string][]FacetValueModel, error) {
+	facets := bson.M{}
+	for _, field := range facetFields {
+		facets[field] = bson.A{
+			//records without the field have nothing to drill down to
+			bson.M{"$match": bson.M{field: bson.M{"$nin": bson.A{nil, ""}}}},
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter.toBSON()}},
+		{{Key: "$facet", Value: facets}},
+	}
+	coll := r.mongoDBClient.Database(r.dbName).Collection(financialDataCollectionName)
+	cursor, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	var results []map[string][]FacetValueModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return map[string][]FacetValueModel{}, nil
+	}
+	return results[0], nil
 }
 
 func NewRepository(cfg *config.Cfg, mongoDBClient *mongo.Client) *Repository {
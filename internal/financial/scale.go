@@ -0,0 +1,118 @@
+package financial
+
+import (
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// unitFormat is how values of a unit are written. Units are matched case
+// insensitively, unknown ones are written without a symbol.
+type unitFormat struct {
+	name   string
+	prefix string
+	suffix string
+	// abbreviate writes large values with a magnitude suffix such as bn.
+	abbreviate bool
+}
+
+var unitFormats = map[string]unitFormat{
+	"dollars": {name: "Dollars", prefix: "$", abbreviate: true},
+	"number":  {name: "Number", abbreviate: true},
+	"percent": {name: "Percent", suffix: "%"},
+}
+
+// magnitudeSuffixes are the abbreviations of large values, largest first.
+var magnitudeSuffixes = []struct {
+	exp    int
+	suffix string
+}{
+	{12, "tn"},
+	{9, "bn"},
+	{6, "m"},
+	{3, "k"},
+}
+
+// formattedValueDecimals is the most decimals a formatted value is written
+// with.
+const formattedValueDecimals = 2
+
+// ScaledValue is a data value with its magnitude applied, e.g. 1116.386 in
+// millions of Dollars is 1116386000 Dollars, written $1.12bn.
+type ScaledValue struct {
+	Value     string
+	Units     string
+	Formatted string
+}
+
+// ScaleDataValue applies magnitude to value. ok is false for suppressed
+// observations, which have no value to scale.
+func ScaleDataValue(value *primitive.Decimal128, magnitude int, units string) (scaled ScaledValue, ok bool) {
+	if value == nil {
+		return ScaledValue{}, false
+	}
+	r, decimals, err := decimalToRat(*value)
+	if err != nil {
+		return ScaledValue{}, false
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(magnitude)), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+	decimals -= magnitude
+	if decimals < 0 {
+		decimals = 0
+	}
+	format, known := unitFormats[strings.ToLower(strings.TrimSpace(units))]
+	if !known {
+		format = unitFormat{name: strings.TrimSpace(units)}
+	}
+	return ScaledValue{
+		Value:     r.FloatString(decimals),
+		Units:     format.name,
+		Formatted: format.format(r),
+	}, true
+}
+
+func (f unitFormat) format(r *big.Rat) string {
+	sign := ""
+	if r.Sign() < 0 {
+		sign = "-"
+		r = new(big.Rat).Abs(r)
+	}
+	number, suffix := roundValue(r), ""
+	if f.abbreviate {
+		for _, m := range magnitudeSuffixes {
+			unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.exp)), nil)
+			n := roundValue(new(big.Rat).Quo(r, new(big.Rat).SetInt(unit)))
+			//compared after rounding, so 999.999k is written 1m
+			if n.Cmp(big.NewRat(1, 1)) >= 0 {
+				number, suffix = n, m.suffix
+				break
+			}
+		}
+	}
+	if number.Sign() == 0 {
+		sign = ""
+	}
+	s := strings.TrimRight(number.FloatString(formattedValueDecimals), "0")
+	s = strings.TrimSuffix(s, ".")
+	return sign + f.prefix + s + suffix + f.suffix
+}
+
+// roundValue rounds r to formattedValueDecimals decimals.
+func roundValue(r *big.Rat) *big.Rat {
+	rounded, _ := new(big.Rat).SetString(r.FloatString(formattedValueDecimals))
+	return rounded
+}
+
+// addScaledValue sets the scaled fields of r, which are left empty for
+// suppressed observations.
+func (r *SingleFinancialDataResult) addScaledValue(m FinancialModel) {
+	scaled, ok := ScaleDataValue(m.DataValue, m.Magnitude, m.Units)
+	if !ok {
+		return
+	}
+	r.ScaledValue = scaled.Value
+	r.ScaledUnits = scaled.Units
+	r.FormattedValue = scaled.Formatted
+}
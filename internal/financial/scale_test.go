@@ -0,0 +1,35 @@
+package financial_test
+
+import (
+	"testing"
+	"we-connect-test/internal/financial"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestScaleDataValue(t *testing.T) {
+	for _, c := range []struct {
+		value     string
+		magnitude int
+		units     string
+		expected  financial.ScaledValue
+	}{
+		{"1116.386", 6, "Dollars", financial.ScaledValue{Value: "1116386000", Units: "Dollars", Formatted: "$1.12bn"}},
+		{"1116.386", 3, "dollars", financial.ScaledValue{Value: "1116386", Units: "Dollars", Formatted: "$1.12m"}},
+		{"-12.5", 6, "Dollars", financial.ScaledValue{Value: "-12500000", Units: "Dollars", Formatted: "-$12.5m"}},
+		{"999.9999", 3, "Number", financial.ScaledValue{Value: "999999.9", Units: "Number", Formatted: "1m"}},
+		{"512", 0, "Number", financial.ScaledValue{Value: "512", Units: "Number", Formatted: "512"}},
+		{"12.345", 0, "Percent", financial.ScaledValue{Value: "12.345", Units: "Percent", Formatted: "12.35%"}},
+		{"5", 0, "Index", financial.ScaledValue{Value: "5", Units: "Index", Formatted: "5"}},
+	} {
+		d, err := primitive.ParseDecimal128(c.value)
+		assert.Nil(t, err)
+		scaled, ok := financial.ScaleDataValue(&d, c.magnitude, c.units)
+		assert.True(t, ok)
+		assert.Equal(t, scaled, c.expected, c.value)
+	}
+
+	_, ok := financial.ScaleDataValue(nil, 6, "Dollars")
+	assert.False(t, ok)
+}
@@ -0,0 +1,134 @@
+package financial
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"we-connect-test/internal/response"
+
+	"go.uber.org/zap"
+)
+
+type GetSeriesListParams struct {
+	Page     int `form:"page"`
+	PageSize int `form:"pageSize"`
+	FinancialFilterParams
+}
+
+type GetSeriesObservationsParams struct {
+	SeriesReference string `form:"-"`
+	FinancialFilterParams
+}
+
+type SingleSeriesResult struct {
+	SeriesReference  string `json:"seriesReference"`
+	Units            string `json:"units"`
+	Magnitude        string `json:"magnitude"`
+	Subject          string `json:"subject"`
+	Group            string `json:"group"`
+	SeriesTitle1     string `json:"seriesTitle1"`
+	SeriesTitle2     string `json:"seriesTitle2"`
+	SeriesTitle3     string `json:"seriesTitle3"`
+	SeriesTitle4     string `json:"seriesTitle4"`
+	SeriesTitle5     string `json:"seriesTitle5"`
+	ObservationCount int    `json:"observationCount"`
+	FirstPeriod      string `json:"firstPeriod"`
+	LastPeriod       string `json:"lastPeriod"`
+}
+
+type SingleObservationResult struct {
+	ID         string `json:"id"`
+	Period     string `json:"period"`
+	DataValue  string `json:"dataValue"`
+	Suppressed string `json:"suppressed"`
+	Status     string `json:"status"`
+}
+
+func (s *Service) GetSeriesList(
+	ctx context.Context,
+	params GetSeriesListParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if params.Page < 0 {
+		params.Page = 0
+	}
+	if params.PageSize < 2 {
+		params.PageSize = 2
+	}
+	if params.PageSize > 100 {
+		params.PageSize = 100
+	}
+	filter, err := params.toFinancialFilter()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	models, err := s.repo.GetSeriesList(ctx, filter, params.Page*params.PageSize, params.PageSize)
+	if err != nil {
+		s.logger.Error("cannot GetSeriesList",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetSeriesList"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make([]SingleSeriesResult, len(models))
+	for i, m := range models {
+		res[i] = toSingleSeriesResult(m)
+	}
+	return response.Success(res, "")
+}
+
+// GetSeriesObservations returns the observations of one series ordered by
+// period. A series without any observation is not found, even when filters
+// are what excluded them.
+func (s *Service) GetSeriesObservations(
+	ctx context.Context,
+	params GetSeriesObservationsParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	params.FinancialFilterParams.SeriesReference = params.SeriesReference
+	filter, err := params.toFinancialFilter()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	models, err := s.repo.GetObservations(ctx, filter)
+	if err != nil {
+		s.logger.Error("cannot GetObservations",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetSeriesObservations"),
+			zap.String("seriesReference", params.SeriesReference),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	if len(models) == 0 {
+		return response.Error("not found", http.StatusNotFound, nil)
+	}
+	res := make([]SingleObservationResult, len(models))
+	for i, m := range models {
+		res[i] = SingleObservationResult{
+			ID:         m.ID.Hex(),
+			Period:     m.Period.String(),
+			DataValue:  formatDataValue(m.DataValue),
+			Suppressed: m.Suppressed,
+			Status:     m.Status,
+		}
+	}
+	return response.Success(res, "")
+}
+
+func toSingleSeriesResult(m SeriesModel) SingleSeriesResult {
+	return SingleSeriesResult{
+		SeriesReference:  m.SeriesReference,
+		Units:            m.Units,
+		Magnitude:        strconv.Itoa(m.Magnitude),
+		Subject:          m.Subject,
+		Group:            m.Group,
+		SeriesTitle1:     m.SeriesTitle1,
+		SeriesTitle2:     m.SeriesTitle2,
+		SeriesTitle3:     m.SeriesTitle3,
+		SeriesTitle4:     m.SeriesTitle4,
+		SeriesTitle5:     m.SeriesTitle5,
+		ObservationCount: m.ObservationCount,
+		FirstPeriod:      m.FirstPeriod.String(),
+		LastPeriod:       m.LastPeriod.String(),
+	}
+}
@@ -3,21 +3,84 @@ package financial
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 	"we-connect-test/internal/response"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
+// maxIDs is the most records fetched by id in one request, the same as the
+// largest page.
+const maxIDs = 100
+
 type Service struct {
 	repo   *Repository
 	logger *zap.Logger
 }
 
+// FinancialFilterParams are the query parameters that filter financial data,
+// shared by the endpoints that read it.
+type FinancialFilterParams struct {
+	SeriesReference string `form:"seriesReference"`
+	PeriodFrom      string `form:"periodFrom"`
+	PeriodTo        string `form:"periodTo"`
+	Status          string `form:"status"`
+	Units           string `form:"units"`
+	Subject         string `form:"subject"`
+	Group           string `form:"group"`
+	SeriesTitle1    string `form:"seriesTitle1"`
+	SeriesTitle2    string `form:"seriesTitle2"`
+	SeriesTitle3    string `form:"seriesTitle3"`
+	SeriesTitle4    string `form:"seriesTitle4"`
+	SeriesTitle5    string `form:"seriesTitle5"`
+}
+
 type GetFinancialDataListParams struct {
 	Page     int `form:"page"`
 	PageSize int `form:"pageSize"`
+	FinancialFilterParams
+	// Sort is a field name, prefixed with - for descending order.
+	Sort string `form:"sort"`
+	// Cursor is a nextCursor or prevCursor of an earlier response. It takes
+	// precedence over Page.
+	Cursor    string `form:"cursor"`
+	WithTotal bool   `form:"withTotal"`
+	// IDs is a comma separated list of ids. When set exactly those records
+	// are returned, in the same order, and the other parameters are ignored.
+	IDs string `form:"ids"`
+	// Scaled adds the values with their magnitude applied to the results.
+	Scaled bool `form:"scaled"`
+	// Fields is a comma separated list of the fields to return, all of them
+	// when empty.
+	Fields string `form:"fields"`
+}
+
+type GetFinancialDataParams struct {
+	ID     string `json:"-"`
+	Scaled bool   `form:"scaled"`
+}
+
+type SearchFinancialDataParams struct {
+	Query    string `form:"q"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"pageSize"`
+	FinancialFilterParams
+	Scaled bool   `form:"scaled"`
+	Fields string `form:"fields"`
+}
+
+// ListMeta is returned next to the data of a list. Total is only set when
+// asked for with withTotal.
+type ListMeta struct {
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
 }
 
 type SingleFinancialDataResult struct {
@@ -36,47 +99,75 @@ type SingleFinancialDataResult struct {
 	SeriesTitle3    string `json:"seriesTitle3"`
 	SeriesTitle4    string `json:"seriesTitle4"`
 	SeriesTitle5    string `json:"seriesTitle5"`
+	Revision        int64  `json:"revision"`
+	DeletedAt       string `json:"deletedAt,omitempty"`
+	// ScaledValue, ScaledUnits and FormattedValue are only set when asked
+	// for, see ScaleDataValue.
+	ScaledValue    string `json:"scaledValue,omitempty"`
+	ScaledUnits    string `json:"scaledUnits,omitempty"`
+	FormattedValue string `json:"formattedValue,omitempty"`
 }
 
+// CreateFinancialDataParams are validated with the tags of
+// RegisterValidations. Suppressed records have no dataValue.
 type CreateFinancialDataParams struct {
-	SeriesReference string `json:"seriesReference"`
-	Period          string `json:"period"`
-	DataValue       string `json:"dataValue"`
-	Suppressed      string `json:"suppressed"`
-	Status          string `json:"status"`
-	Units           string `json:"units"`
-	Magnitude       string `json:"magnitude"`
-	Subject         string `json:"subject"`
-	Group           string `json:"group"`
-	SeriesTitle1    string `json:"seriesTitle1"`
-	SeriesTitle2    string `json:"seriesTitle2"`
-	SeriesTitle3    string `json:"seriesTitle3"`
-	SeriesTitle4    string `json:"seriesTitle4"`
-	SeriesTitle5    string `json:"seriesTitle5"`
+	SeriesReference string `json:"seriesReference" binding:"required,max=64"`
+	Period          string `json:"period" binding:"required,period"`
+	DataValue       string `json:"dataValue" binding:"omitempty,datavalue"`
+	Suppressed      string `json:"suppressed" binding:"omitempty,oneof=Y"`
+	Status          string `json:"status" binding:"omitempty,oneof=F R C"`
+	Units           string `json:"units" binding:"max=64"`
+	Magnitude       string `json:"magnitude" binding:"required,magnitude"`
+	Subject         string `json:"subject" binding:"max=256"`
+	Group           string `json:"group" binding:"max=256"`
+	SeriesTitle1    string `json:"seriesTitle1" binding:"max=256"`
+	SeriesTitle2    string `json:"seriesTitle2" binding:"max=256"`
+	SeriesTitle3    string `json:"seriesTitle3" binding:"max=256"`
+	SeriesTitle4    string `json:"seriesTitle4" binding:"max=256"`
+	SeriesTitle5    string `json:"seriesTitle5" binding:"max=256"`
 }
 
+// UpdateFinancialDataParams only sets the fields that are not nil, with the
+// rules of CreateFinancialDataParams.
 type UpdateFinancialDataParams struct {
 	ID              string  `json:"id"`
-	SeriesReference *string `json:"seriesReference"`
-	Period          *string `json:"period"`
-	DataValue       *string `json:"dataValue"`
-	Suppressed      *string `json:"suppressed"`
-	Status          *string `json:"status"`
-	Units           *string `json:"units"`
-	Magnitude       *string `json:"magnitude"`
-	Subject         *string `json:"subject"`
-	Group           *string `json:"group"`
-	SeriesTitle1    *string `json:"seriesTitle1"`
-	SeriesTitle2    *string `json:"seriesTitle2"`
-	SeriesTitle3    *string `json:"seriesTitle3"`
-	SeriesTitle4    *string `json:"seriesTitle4"`
-	SeriesTitle5    *string `json:"seriesTitle5"`
+	SeriesReference *string `json:"seriesReference" binding:"omitempty,min=1,max=64"`
+	Period          *string `json:"period" binding:"omitempty,period"`
+	DataValue       *string `json:"dataValue" binding:"omitempty,datavalue"`
+	Suppressed      *string `json:"suppressed" binding:"omitempty,oneof=Y ''"`
+	Status          *string `json:"status" binding:"omitempty,oneof=F R C ''"`
+	Units           *string `json:"units" binding:"omitempty,max=64"`
+	Magnitude       *string `json:"magnitude" binding:"omitempty,magnitude"`
+	Subject         *string `json:"subject" binding:"omitempty,max=256"`
+	Group           *string `json:"group" binding:"omitempty,max=256"`
+	SeriesTitle1    *string `json:"seriesTitle1" binding:"omitempty,max=256"`
+	SeriesTitle2    *string `json:"seriesTitle2" binding:"omitempty,max=256"`
+	SeriesTitle3    *string `json:"seriesTitle3" binding:"omitempty,max=256"`
+	SeriesTitle4    *string `json:"seriesTitle4" binding:"omitempty,max=256"`
+	SeriesTitle5    *string `json:"seriesTitle5" binding:"omitempty,max=256"`
+
+	// IfRevisions, when set, only updates the record while it is at one of
+	// the revisions, the ones of the If-Match header.
+	IfRevisions []int64 `json:"-"`
 }
 
-type DeleteFinancialDataParams struct {
+// ReplaceFinancialDataParams replaces every field of the record with ID, the
+// fields left out are cleared.
+type ReplaceFinancialDataParams struct {
+	ID          string  `json:"-"`
+	IfRevisions []int64 `json:"-"`
+	CreateFinancialDataParams
+}
+
+type CreateFinancialDataResult struct {
 	ID string `json:"id"`
 }
 
+type DeleteFinancialDataParams struct {
+	ID          string  `json:"id"`
+	IfRevisions []int64 `json:"-"`
+}
+
 func (s *Service) GetFinancialDataList(
 	ctx context.Context,
 	params GetFinancialDataListParams,
@@ -90,58 +181,258 @@ func (s *Service) GetFinancialDataList(
 	if params.PageSize > 100 {
 		params.PageSize = 100
 	}
-	models, err := s.repo.GetFinancialDataByPagination(ctx, params.Page, params.PageSize)
+	fields, err := ParseFields(params.Fields)
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	if params.IDs != "" {
+		return s.getFinancialDataByIDs(ctx, params, fields)
+	}
+	q, err := params.toFinancialListQuery()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	q.Fields = fields
+	if params.Scaled {
+		q.Fields = fields.withScaledValue()
+	}
+	models, hasMore, err := s.repo.GetFinancialDataList(ctx, q)
 	if err != nil {
-		s.logger.Error("cannot GetFinancialDataByPagination",
+		s.logger.Error("cannot GetFinancialDataList",
 			zap.Error(err),
 			zap.String("service", "financialService"),
 			zap.String("method", "GetFinancialDataList"),
 		)
 		return response.Error("something went wrong", http.StatusInternalServerError, nil)
 	}
+	meta, err := listMeta(q, models, hasMore)
+	if err != nil {
+		s.logger.Error("cannot create list cursors",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetFinancialDataList"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	if params.WithTotal {
+		total, err := s.repo.CountFinancialData(ctx, q.Filter)
+		if err != nil {
+			s.logger.Error("cannot CountFinancialData",
+				zap.Error(err),
+				zap.String("service", "financialService"),
+				zap.String("method", "GetFinancialDataList"),
+			)
+			return response.Error("something went wrong", http.StatusInternalServerError, nil)
+		}
+		meta.Total = &total
+	}
 	res := make([]SingleFinancialDataResult, len(models))
 	for i, m := range models {
-		res[i] = SingleFinancialDataResult{
-			ID:              m.ID.Hex(),
-			SeriesReference: m.SeriesReference,
-			Period:          m.Period,
-			DataValue:       m.DataValue,
-			Suppressed:      m.Suppressed,
-			Status:          m.Status,
-			Units:           m.Units,
-			Magnitude:       m.Magnitude,
-			Subject:         m.Subject,
-			Group:           m.Group,
-			SeriesTitle1:    m.SeriesTitle1,
-			SeriesTitle2:    m.SeriesTitle2,
-			SeriesTitle3:    m.SeriesTitle3,
-			SeriesTitle4:    m.SeriesTitle4,
-			SeriesTitle5:    m.SeriesTitle5,
+		res[i] = toSingleFinancialDataResult(m)
+		if params.Scaled {
+			res[i].addScaledValue(m)
 		}
 	}
+	return response.SuccessWithMeta(fields.results(res), meta, "")
+}
+
+func (s *Service) GetFinancialData(
+	ctx context.Context,
+	params GetFinancialDataParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if !primitive.IsValidObjectID(params.ID) {
+		return response.Error("invalid id", http.StatusBadRequest, nil)
+	}
+	m, err := s.repo.GetFinancialDataByID(ctx, params.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return response.Error("not found", http.StatusNotFound, nil)
+	}
+	if err != nil {
+		s.logger.Error("cannot GetFinancialDataByID",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetFinancialData"),
+			zap.String("id", params.ID),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := toSingleFinancialDataResult(m)
+	if params.Scaled {
+		res.addScaledValue(m)
+	}
 	return response.Success(res, "")
 }
 
+// getFinancialDataByIDs returns the records of the comma separated id list
+// of params in the order asked for. Malformed ids are a 400 and missing ones
+// a 404, both listing the offending ids.
+func (s *Service) getFinancialDataByIDs(
+	ctx context.Context,
+	params GetFinancialDataListParams,
+	fields FinancialFields,
+) (apiResponse response.ApiResponse, statusCode int) {
+	var ids []primitive.ObjectID
+	var invalid []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(params.IDs, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			invalid = append(invalid, id)
+			continue
+		}
+		ids = append(ids, objectID)
+	}
+	if len(invalid) > 0 {
+		return response.Error("invalid ids", http.StatusBadRequest, map[string][]string{"invalidIds": invalid})
+	}
+	if len(ids) > maxIDs {
+		return response.Error(fmt.Sprintf("at most %d ids can be fetched at once", maxIDs), http.StatusBadRequest, nil)
+	}
+	queryFields := fields
+	if params.Scaled {
+		queryFields = fields.withScaledValue()
+	}
+	models, err := s.repo.GetFinancialDataByIDs(ctx, ids, queryFields)
+	if err != nil {
+		s.logger.Error("cannot GetFinancialDataByIDs",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "GetFinancialDataList"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	found := make(map[primitive.ObjectID]FinancialModel, len(models))
+	for _, m := range models {
+		found[m.ID] = m
+	}
+	res := make([]SingleFinancialDataResult, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		m, ok := found[id]
+		if !ok {
+			missing = append(missing, id.Hex())
+			continue
+		}
+		r := toSingleFinancialDataResult(m)
+		if params.Scaled {
+			r.addScaledValue(m)
+		}
+		res = append(res, r)
+	}
+	if len(missing) > 0 {
+		return response.Error("not found", http.StatusNotFound, map[string][]string{"missingIds": missing})
+	}
+	return response.Success(fields.results(res), "")
+}
+
+// SearchFinancialData returns the records matching the words of params.Query
+// in their titles, subject or group, the most relevant first. The list
+// filters narrow the search further.
+func (s *Service) SearchFinancialData(
+	ctx context.Context,
+	params SearchFinancialDataParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Query == "" {
+		return response.Error("q is required", http.StatusBadRequest, nil)
+	}
+	if params.Page < 0 {
+		params.Page = 0
+	}
+	if params.PageSize < 2 {
+		params.PageSize = 2
+	}
+	if params.PageSize > 100 {
+		params.PageSize = 100
+	}
+	filter, err := params.toFinancialFilter()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	fields, err := ParseFields(params.Fields)
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	queryFields := fields
+	if params.Scaled {
+		queryFields = fields.withScaledValue()
+	}
+	models, err := s.repo.SearchFinancialData(ctx, params.Query, filter, queryFields, params.Page*params.PageSize, params.PageSize)
+	if err != nil {
+		s.logger.Error("cannot SearchFinancialData",
+			zap.Error(err),
+			zap.String("service", "financialService"),
+			zap.String("method", "SearchFinancialData"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make([]SingleFinancialDataResult, len(models))
+	for i, m := range models {
+		res[i] = toSingleFinancialDataResult(m)
+		if params.Scaled {
+			res[i].addScaledValue(m)
+		}
+	}
+	return response.Success(fields.results(res), "")
+}
+
+// listMeta returns the cursors of the pages around models. The page a
+// cursor came from always exists, the page further on only when hasMore.
+func listMeta(q FinancialListQuery, models []FinancialModel, hasMore bool) (ListMeta, error) {
+	meta := ListMeta{}
+	if len(models) == 0 {
+		return meta, nil
+	}
+	backward := q.After != nil && q.After.Backward
+	hasNext := hasMore
+	hasPrev := q.After != nil || q.Skip > 0
+	if backward {
+		hasNext, hasPrev = true, hasMore
+	}
+	if hasNext {
+		c, err := newListCursor(models[len(models)-1], q.Sort, false)
+		if err != nil {
+			return meta, err
+		}
+		meta.NextCursor, err = c.encode()
+		if err != nil {
+			return meta, err
+		}
+	}
+	if hasPrev {
+		c, err := newListCursor(models[0], q.Sort, true)
+		if err != nil {
+			return meta, err
+		}
+		meta.PrevCursor, err = c.encode()
+		if err != nil {
+			return meta, err
+		}
+	}
+	return meta, nil
+}
+
 func (s *Service) CreateFinancialDataByUser(
 	ctx context.Context,
 	params CreateFinancialDataParams,
 ) (apiResponse response.ApiResponse, statusCode int) {
-	id, err := s.CreateFinancialData(ctx, FinancialModel{
-		SeriesReference: params.SeriesReference,
-		Period:          params.Period,
-		DataValue:       params.DataValue,
-		Suppressed:      params.Suppressed,
-		Status:          params.Status,
-		Units:           params.Units,
-		Magnitude:       params.Magnitude,
-		Subject:         params.Subject,
-		Group:           params.Group,
-		SeriesTitle1:    params.SeriesTitle1,
-		SeriesTitle2:    params.SeriesTitle2,
-		SeriesTitle3:    params.SeriesTitle3,
-		SeriesTitle4:    params.SeriesTitle4,
-		SeriesTitle5:    params.SeriesTitle5,
-	})
+	m, err := params.toFinancialModel()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	id, err := s.CreateFinancialData(ctx, m)
+	if errors.Is(err, ErrDuplicateFinancialData) {
+		return response.Error(err.Error(), http.StatusConflict, nil)
+	}
+	if errors.Is(err, ErrDeletedFinancialData) {
+		//the id to restore, POST /:id/restore brings the record back
+		return response.Error(err.Error(), http.StatusConflict, CreateFinancialDataResult{ID: id})
+	}
 	if err != nil {
 		s.logger.Error("cannot CreateFinancialData",
 			zap.Error(err),
@@ -150,9 +441,7 @@ func (s *Service) CreateFinancialDataByUser(
 		)
 		return response.Error("something went wrong", http.StatusInternalServerError, nil)
 	}
-	res := make(map[string]string)
-	res["id"] = id
-	return response.Success(res, "")
+	return response.Created(CreateFinancialDataResult{ID: id}, "")
 }
 
 func (s *Service) CreateFinancialData(
@@ -162,13 +451,51 @@ func (s *Service) CreateFinancialData(
 	return s.repo.CreateFinancialData(ctx, data)
 }
 
+// UpsertFinancialData stores data keyed on its seriesReference and period,
+// so importing the same row twice does not duplicate it. The record of a
+// deleted one is not restored, ErrDeletedFinancialData is returned instead.
+func (s *Service) UpsertFinancialData(
+	ctx context.Context,
+	data FinancialModel,
+) error {
+	return s.repo.UpsertFinancialData(ctx, data)
+}
+
+// UpsertManyFinancialData is the batched form of UpsertFinancialData. The
+// returned map holds the error of every model that was not stored, keyed by
+// its index in data.
+func (s *Service) UpsertManyFinancialData(
+	ctx context.Context,
+	data []FinancialModel,
+) (map[int]error, error) {
+	return s.repo.UpsertManyFinancialData(ctx, data)
+}
+
+// UpdateFinancialData sets the fields of params on the record and returns
+// the updated record.
 func (s *Service) UpdateFinancialData(
 	ctx context.Context,
 	params UpdateFinancialDataParams,
 ) (apiResponse response.ApiResponse, statusCode int) {
-	_, err := s.repo.GetFinancialDataByID(ctx, params.ID)
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
-		s.logger.Error("cannot GetFinancialDataByID",
+	if !primitive.IsValidObjectID(params.ID) {
+		return response.Error("invalid id", http.StatusBadRequest, nil)
+	}
+	updateModel, err := params.toFinancialUpdateModel()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	m, err := s.repo.UpdateFinancialData(ctx, params.ID, updateModel, params.IfRevisions)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return response.Error("not found", http.StatusNotFound, nil)
+	}
+	if errors.Is(err, ErrRevisionMismatch) {
+		return response.Error(err.Error(), http.StatusPreconditionFailed, nil)
+	}
+	if errors.Is(err, ErrDuplicateFinancialData) {
+		return response.Error(err.Error(), http.StatusConflict, nil)
+	}
+	if err != nil {
+		s.logger.Error("cannot UpdateFinancialData",
 			zap.Error(err),
 			zap.String("service", "financialService"),
 			zap.String("method", "UpdateFinancialDataByUser"),
@@ -176,58 +503,63 @@ func (s *Service) UpdateFinancialData(
 		)
 		return response.Error("something went wrong", http.StatusInternalServerError, nil)
 	}
+	//the record as written, its revision is the one of this update
+	return response.Success(toSingleFinancialDataResult(m), "")
+}
+
+// ReplaceFinancialData overwrites the record with the fields of params and
+// returns it.
+func (s *Service) ReplaceFinancialData(
+	ctx context.Context,
+	params ReplaceFinancialDataParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if !primitive.IsValidObjectID(params.ID) {
+		return response.Error("invalid id", http.StatusBadRequest, nil)
+	}
+	m, err := params.toFinancialModel()
+	if err != nil {
+		return response.Error(err.Error(), http.StatusBadRequest, nil)
+	}
+	m, err = s.repo.ReplaceFinancialData(ctx, params.ID, m, params.IfRevisions)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return response.Error("not found", http.StatusNotFound, nil)
 	}
-	err = s.repo.UpdateFinancialData(ctx, params.ID, FinancialUpdateModel{
-		SeriesReference: params.SeriesReference,
-		Period:          params.Period,
-		DataValue:       params.DataValue,
-		Suppressed:      params.Suppressed,
-		Status:          params.Status,
-		Units:           params.Units,
-		Magnitude:       params.Magnitude,
-		Subject:         params.Subject,
-		Group:           params.Group,
-		SeriesTitle1:    params.SeriesTitle1,
-		SeriesTitle2:    params.SeriesTitle2,
-		SeriesTitle3:    params.SeriesTitle3,
-		SeriesTitle4:    params.SeriesTitle4,
-		SeriesTitle5:    params.SeriesTitle5,
-	})
+	if errors.Is(err, ErrRevisionMismatch) {
+		return response.Error(err.Error(), http.StatusPreconditionFailed, nil)
+	}
+	if errors.Is(err, ErrDuplicateFinancialData) {
+		return response.Error(err.Error(), http.StatusConflict, nil)
+	}
 	if err != nil {
-		s.logger.Error("cannot UpdateFinancialData",
+		s.logger.Error("cannot ReplaceFinancialData",
 			zap.Error(err),
 			zap.String("service", "financialService"),
-			zap.String("method", "UpdateFinancialDataByUser"),
+			zap.String("method", "ReplaceFinancialData"),
 			zap.String("id", params.ID),
 		)
 		return response.Error("something went wrong", http.StatusInternalServerError, nil)
 	}
-	res := make(map[string]string)
-	return response.Success(res, "")
+	return response.Success(toSingleFinancialDataResult(m), "")
 }
 
+// DeleteFinancialData hides the record from every read, it can be restored
+// with RestoreFinancialData until it is purged.
 func (s *Service) DeleteFinancialData(
 	ctx context.Context,
 	params DeleteFinancialDataParams,
 ) (apiResponse response.ApiResponse, statusCode int) {
-	_, err := s.repo.GetFinancialDataByID(ctx, params.ID)
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
-		s.logger.Error("cannot GetFinancialDataByID",
-			zap.Error(err),
-			zap.String("service", "financialService"),
-			zap.String("method", "DeleteFinancialDataByUser"),
-			zap.String("id", params.ID),
-		)
-		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	if !primitive.IsValidObjectID(params.ID) {
+		return response.Error("invalid id", http.StatusBadRequest, nil)
 	}
+	err := s.repo.DeleteFinancialData(ctx, params.ID, time.Now(), params.IfRevisions)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return response.Error("not found", http.StatusNotFound, nil)
 	}
-	err = s.repo.DeleteFinancialData(ctx, params.ID)
+	if errors.Is(err, ErrRevisionMismatch) {
+		return response.Error(err.Error(), http.StatusPreconditionFailed, nil)
+	}
 	if err != nil {
-		s.logger.Error("cannot GetFinancialDataByID",
+		s.logger.Error("cannot DeleteFinancialData",
 			zap.Error(err),
 			zap.String("service", "financialService"),
 			zap.String("method", "DeleteFinancialDataByUser"),
@@ -235,8 +567,195 @@ func (s *Service) DeleteFinancialData(
 		)
 		return response.Error("something went wrong", http.StatusInternalServerError, nil)
 	}
-	res := make(map[string]string)
-	return response.Success(res, "")
+	return response.NoContent()
+}
+
+// MigrateFinancialData converts the financial data stored before the fields
+// were typed. What cannot be converted is moved to the
+// financialDataQuarantine collection to be fixed by hand.
+func (s *Service) MigrateFinancialData(ctx context.Context) error {
+	migrated, failures, err := s.repo.MigrateLegacyTypes(ctx)
+	for _, f := range failures {
+		s.logger.Warn("cannot migrate financial data, moved it to quarantine",
+			zap.Error(f.Err),
+			zap.String("service", "financialService"),
+			zap.String("method", "MigrateFinancialData"),
+			zap.String("id", f.ID),
+		)
+	}
+	if migrated > 0 {
+		s.logger.Info("migrated financial data to typed fields", zap.Int("count", migrated))
+	}
+	return err
+}
+
+// EnsureIndexes removes rows duplicated by earlier imports and then creates
+// the indexes, including the unique seriesReference and period index.
+func (s *Service) EnsureIndexes(ctx context.Context) error {
+	removed, err := s.repo.RemoveDuplicates(ctx)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		s.logger.Info("removed duplicated financial data", zap.Int64("count", removed))
+	}
+	return s.repo.EnsureIndexes(ctx)
+}
+
+func (p GetFinancialDataListParams) toFinancialListQuery() (FinancialListQuery, error) {
+	filter, err := p.toFinancialFilter()
+	if err != nil {
+		return FinancialListQuery{}, err
+	}
+	sort, err := ParseSort(p.Sort)
+	if err != nil {
+		return FinancialListQuery{}, err
+	}
+	q := FinancialListQuery{
+		Filter: filter,
+		Sort:   sort,
+		Skip:   p.Page * p.PageSize,
+		Limit:  p.PageSize,
+	}
+	if p.Cursor != "" {
+		after, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return FinancialListQuery{}, err
+		}
+		//the cursor carries its sort, a different explicit one is a mistake
+		if p.Sort != "" && after.sort() != sort {
+			return FinancialListQuery{}, fmt.Errorf("%w: cursor was created with another sort", ErrInvalidCursor)
+		}
+		q.Sort = after.sort()
+		q.Skip = 0
+		q.After = &after
+	}
+	return q, nil
+}
+
+func (p FinancialFilterParams) toFinancialFilter() (FinancialFilter, error) {
+	f := FinancialFilter{
+		SeriesReference: p.SeriesReference,
+		Status:          p.Status,
+		Units:           p.Units,
+		Subject:         p.Subject,
+		Group:           p.Group,
+		SeriesTitle1:    p.SeriesTitle1,
+		SeriesTitle2:    p.SeriesTitle2,
+		SeriesTitle3:    p.SeriesTitle3,
+		SeriesTitle4:    p.SeriesTitle4,
+		SeriesTitle5:    p.SeriesTitle5,
+	}
+	if p.PeriodFrom != "" {
+		period, err := ParsePeriod(p.PeriodFrom)
+		if err != nil {
+			return FinancialFilter{}, fmt.Errorf("periodFrom: %w", err)
+		}
+		f.PeriodFrom = &period
+	}
+	if p.PeriodTo != "" {
+		period, err := ParsePeriod(p.PeriodTo)
+		if err != nil {
+			return FinancialFilter{}, fmt.Errorf("periodTo: %w", err)
+		}
+		f.PeriodTo = &period
+	}
+	if f.PeriodFrom != nil && f.PeriodTo != nil && f.PeriodTo.Before(*f.PeriodFrom) {
+		return FinancialFilter{}, errors.New("periodFrom must not be after periodTo")
+	}
+	return f, nil
+}
+
+func (p CreateFinancialDataParams) toFinancialModel() (FinancialModel, error) {
+	period, err := ParsePeriod(p.Period)
+	if err != nil {
+		return FinancialModel{}, err
+	}
+	dataValue, err := ParseDataValue(p.DataValue)
+	if err != nil {
+		return FinancialModel{}, err
+	}
+	magnitude, err := ParseMagnitude(p.Magnitude)
+	if err != nil {
+		return FinancialModel{}, err
+	}
+	return FinancialModel{
+		SeriesReference: p.SeriesReference,
+		Period:          period,
+		DataValue:       dataValue,
+		Suppressed:      p.Suppressed,
+		Status:          p.Status,
+		Units:           p.Units,
+		Magnitude:       magnitude,
+		Subject:         p.Subject,
+		Group:           p.Group,
+		SeriesTitle1:    p.SeriesTitle1,
+		SeriesTitle2:    p.SeriesTitle2,
+		SeriesTitle3:    p.SeriesTitle3,
+		SeriesTitle4:    p.SeriesTitle4,
+		SeriesTitle5:    p.SeriesTitle5,
+	}, nil
+}
+
+func (p UpdateFinancialDataParams) toFinancialUpdateModel() (FinancialUpdateModel, error) {
+	m := FinancialUpdateModel{
+		SeriesReference: p.SeriesReference,
+		Suppressed:      p.Suppressed,
+		Status:          p.Status,
+		Units:           p.Units,
+		Subject:         p.Subject,
+		Group:           p.Group,
+		SeriesTitle1:    p.SeriesTitle1,
+		SeriesTitle2:    p.SeriesTitle2,
+		SeriesTitle3:    p.SeriesTitle3,
+		SeriesTitle4:    p.SeriesTitle4,
+		SeriesTitle5:    p.SeriesTitle5,
+	}
+	if p.Period != nil {
+		period, err := ParsePeriod(*p.Period)
+		if err != nil {
+			return FinancialUpdateModel{}, err
+		}
+		m.Period = &period
+	}
+	if p.DataValue != nil {
+		dataValue, err := ParseDataValue(*p.DataValue)
+		if err != nil {
+			return FinancialUpdateModel{}, err
+		}
+		m.DataValue = dataValue
+		m.ClearDataValue = dataValue == nil
+	}
+	if p.Magnitude != nil {
+		magnitude, err := ParseMagnitude(*p.Magnitude)
+		if err != nil {
+			return FinancialUpdateModel{}, err
+		}
+		m.Magnitude = &magnitude
+	}
+	return m, nil
+}
+
+func toSingleFinancialDataResult(m FinancialModel) SingleFinancialDataResult {
+	return SingleFinancialDataResult{
+		ID:              m.ID.Hex(),
+		SeriesReference: m.SeriesReference,
+		Period:          m.Period.String(),
+		DataValue:       formatDataValue(m.DataValue),
+		Suppressed:      m.Suppressed,
+		Status:          m.Status,
+		Units:           m.Units,
+		Magnitude:       strconv.Itoa(m.Magnitude),
+		Subject:         m.Subject,
+		Group:           m.Group,
+		SeriesTitle1:    m.SeriesTitle1,
+		SeriesTitle2:    m.SeriesTitle2,
+		SeriesTitle3:    m.SeriesTitle3,
+		SeriesTitle4:    m.SeriesTitle4,
+		SeriesTitle5:    m.SeriesTitle5,
+		Revision:        m.Revision,
+		DeletedAt:       formatDeletedAt(m.DeletedAt),
+	}
 }
 
 func NewService(
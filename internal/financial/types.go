@@ -0,0 +1,86 @@
+package financial
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Period is a quarterly observation period. It is stored as an embedded
+// document with year before quarter so periods compare and sort naturally
+// in mongo.
+type Period struct {
+	Year    int `bson:"year"`
+	Quarter int `bson:"quarter"`
+}
+
+// String formats the period the way Stats NZ publishes it, e.g. 2016.06 for
+// the quarter ending in June 2016.
+func (p Period) String() string {
+	return fmt.Sprintf("%d.%02d", p.Year, p.Quarter*3)
+}
+
+func (p Period) IsZero() bool {
+	return p.Year == 0 && p.Quarter == 0
+}
+
+func (p Period) Before(other Period) bool {
+	return p.Year < other.Year || (p.Year == other.Year && p.Quarter < other.Quarter)
+}
+
+// AddQuarters returns the period n quarters later, or earlier when n is
+// negative.
+func (p Period) AddQuarters(n int) Period {
+	q := p.Year*4 + p.Quarter - 1 + n
+	return Period{Year: q / 4, Quarter: q%4 + 1}
+}
+
+// ParsePeriod parses a period in the YYYY.MM form where MM is the last month
+// of a quarter (03, 06, 09 or 12).
+func ParsePeriod(s string) (Period, error) {
+	s = strings.TrimSpace(s)
+	year, month, ok := strings.Cut(s, ".")
+	if !ok || len(year) != 4 || len(month) != 2 {
+		return Period{}, fmt.Errorf("invalid period %q, expected YYYY.MM", s)
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil || y <= 0 {
+		return Period{}, fmt.Errorf("invalid period year %q", year)
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 3 || m > 12 || m%3 != 0 {
+		return Period{}, fmt.Errorf("invalid period month %q, expected 03, 06, 09 or 12", month)
+	}
+	return Period{Year: y, Quarter: m / 3}, nil
+}
+
+// ParseDataValue parses a decimal data value. Suppressed observations have
+// an empty value, which is returned as nil.
+func ParseDataValue(s string) (*primitive.Decimal128, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	d, err := primitive.ParseDecimal128(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data value %q", s)
+	}
+	return &d, nil
+}
+
+func ParseMagnitude(s string) (int, error) {
+	m, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || m < 0 {
+		return 0, fmt.Errorf("invalid magnitude %q", s)
+	}
+	return m, nil
+}
+
+func formatDataValue(d *primitive.Decimal128) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,65 @@
+package financial_test
+
+import (
+	"testing"
+	"we-connect-test/internal/financial"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePeriod(t *testing.T) {
+	p, err := financial.ParsePeriod("2016.06")
+	assert.Nil(t, err)
+	assert.Equal(t, p, financial.Period{Year: 2016, Quarter: 2})
+	assert.Equal(t, p.String(), "2016.06")
+
+	p, err = financial.ParsePeriod("2017.12")
+	assert.Nil(t, err)
+	assert.Equal(t, p, financial.Period{Year: 2017, Quarter: 4})
+
+	for _, invalid := range []string{"", "2016", "2016.6", "2016.05", "2016.15", "16.06", "abcd.06"} {
+		_, err = financial.ParsePeriod(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestParseDataValue(t *testing.T) {
+	d, err := financial.ParseDataValue("1116.386")
+	assert.Nil(t, err)
+	assert.Equal(t, d.String(), "1116.386")
+
+	//suppressed values are empty in the csv files
+	d, err = financial.ParseDataValue("")
+	assert.Nil(t, err)
+	assert.Nil(t, d)
+
+	_, err = financial.ParseDataValue("abc")
+	assert.NotNil(t, err)
+}
+
+func TestParseMagnitude(t *testing.T) {
+	m, err := financial.ParseMagnitude("6")
+	assert.Nil(t, err)
+	assert.Equal(t, m, 6)
+
+	_, err = financial.ParseMagnitude("")
+	assert.NotNil(t, err)
+	_, err = financial.ParseMagnitude("-1")
+	assert.NotNil(t, err)
+}
+
+func TestPeriodBefore(t *testing.T) {
+	p := financial.Period{Year: 2016, Quarter: 4}
+	assert.True(t, p.Before(financial.Period{Year: 2017, Quarter: 1}))
+	assert.False(t, p.Before(financial.Period{Year: 2016, Quarter: 4}))
+	assert.False(t, p.Before(financial.Period{Year: 2016, Quarter: 3}))
+}
+
+func TestPeriodAddQuarters(t *testing.T) {
+	p := financial.Period{Year: 2016, Quarter: 1}
+	assert.Equal(t, p.AddQuarters(-1), financial.Period{Year: 2015, Quarter: 4})
+	assert.Equal(t, p.AddQuarters(-4), financial.Period{Year: 2015, Quarter: 1})
+	assert.Equal(t, p.AddQuarters(3), financial.Period{Year: 2016, Quarter: 4})
+	assert.Equal(t, p.AddQuarters(5), financial.Period{Year: 2017, Quarter: 2})
+	assert.Equal(t, p.AddQuarters(0), p)
+}
@@ -0,0 +1,48 @@
+package financial
+
+import (
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxMagnitude is the largest power of ten values can be given in.
+const MaxMagnitude = 15
+
+// RegisterValidations adds the validation tags used by the params of the
+// service to v:
+//   - period, a period such as 2016.06
+//   - datavalue, a decimal number
+//   - magnitude, a whole number from 0 to MaxMagnitude
+func RegisterValidations(v *validator.Validate) error {
+	validations := map[string]validator.Func{
+		"period":    validatePeriod,
+		"datavalue": validateDataValue,
+		"magnitude": validateMagnitude,
+	}
+	for tag, fn := range validations {
+		err := v.RegisterValidation(tag, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePeriod(fl validator.FieldLevel) bool {
+	_, err := ParsePeriod(fl.Field().String())
+	return err == nil
+}
+
+// validateDataValue accepts the values ParseDataValue does, except NaN and
+// infinities, which are not observations.
+func validateDataValue(fl validator.FieldLevel) bool {
+	d, err := ParseDataValue(fl.Field().String())
+	if err != nil {
+		return false
+	}
+	return d == nil || (!d.IsNaN() && d.IsInf() == 0)
+}
+
+func validateMagnitude(fl validator.FieldLevel) bool {
+	m, err := ParseMagnitude(fl.Field().String())
+	return err == nil && m <= MaxMagnitude
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"we-connect-test/internal/financial"
+	"we-connect-test/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultMaxBodyBytes is the largest request body accepted when
+// http.maxBodyBytes is not configured.
+const DefaultMaxBodyBytes = 1 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// registerValidations sets up the validator gin binds with, so field errors
+// are named like the json fields and the custom tags of the services are
+// known.
+func registerValidations() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return financial.RegisterValidations(v)
+}
+
+// limitBodySize rejects request bodies larger than maxBytes while they are
+// read.
+func limitBodySize(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// bindJSON decodes the json body of c into obj and validates it. Unknown
+// fields are an error, so misspelled fields are not silently ignored.
+func bindJSON(c *gin.Context, obj interface{}) error {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(obj)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return errBodyTooLarge
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("request body must be a single json value")
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// abortWithBindingError answers a request bindJSON failed on. Validation
+// errors list a message per json field in the data of the response.
+func abortWithBindingError(c *gin.Context, err error) {
+	if errors.Is(err, errBodyTooLarge) {
+		resp, statusCode := response.Error(err.Error(), http.StatusRequestEntityTooLarge, nil)
+		c.AbortWithStatusJSON(statusCode, resp)
+		return
+	}
+	fields := make(map[string]string)
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrors):
+		for _, e := range validationErrors {
+			fields[fieldPath(e.Namespace())] = validationMessage(e)
+		}
+	case errors.As(err, &typeError):
+		fields[typeError.Field] = fmt.Sprintf("must be a %s", typeError.Type.Kind())
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if unquoteErr == nil {
+			fields[name] = "is not a known field"
+		}
+	}
+	if len(fields) == 0 {
+		resp, statusCode := response.Error(err.Error(), http.StatusBadRequest, nil)
+		c.AbortWithStatusJSON(statusCode, resp)
+		return
+	}
+	resp, statusCode := response.Error("invalid request", http.StatusBadRequest, fields)
+	c.AbortWithStatusJSON(statusCode, resp)
+}
+
+// fieldPath drops the name of the validated struct validator starts
+// namespaces with, leaving the json path such as operations[2].create.period.
+func fieldPath(namespace string) string {
+	_, path, ok := strings.Cut(namespace, ".")
+	if !ok {
+		return namespace
+	}
+	return path
+}
+
+func validationMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s %s", e.Param(), lengthUnit(e))
+	case "max":
+		return fmt.Sprintf("must be at most %s %s", e.Param(), lengthUnit(e))
+	case "oneof":
+		var values []string
+		for _, v := range strings.Fields(e.Param()) {
+			//'' allows clearing the field, which is not worth listing
+			if v != "''" {
+				values = append(values, v)
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(values, ", "))
+	case "period":
+		return "must be a period like 2016.06"
+	case "datavalue":
+		return "must be a decimal number"
+	case "magnitude":
+		return fmt.Sprintf("must be a whole number from 0 to %d", financial.MaxMagnitude)
+	}
+	return fmt.Sprintf("is invalid (%s)", e.Tag())
+}
+
+// lengthUnit is what the min and max of e count.
+func lengthUnit(e validator.FieldError) string {
+	if e.Kind() == reflect.Slice {
+		return "items"
+	}
+	return "characters"
+}
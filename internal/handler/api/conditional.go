@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"we-connect-test/internal/financial"
+	"we-connect-test/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag is the entity tag of a record at revision.
+func etag(revision int64) string {
+	return strconv.Quote(strconv.FormatInt(revision, 10))
+}
+
+// setETag sets the ETag header when resp holds a single record.
+func setETag(c *gin.Context, resp response.ApiResponse) {
+	if r, ok := resp.Data.(financial.SingleFinancialDataResult); ok {
+		c.Header("ETag", etag(r.Revision))
+	}
+}
+
+// ifMatchRevisions returns the revisions of the If-Match header of c, nil
+// when it is not sent or is *. Weak and malformed tags never match, so a
+// header made only of those aborts with 412 and ok false.
+func ifMatchRevisions(c *gin.Context) (revisions []int64, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+		revision, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+	if len(revisions) == 0 {
+		resp, statusCode := response.Error(financial.ErrRevisionMismatch.Error(), http.StatusPreconditionFailed, nil)
+		c.AbortWithStatusJSON(statusCode, resp)
+		return nil, false
+	}
+	return revisions, true
+}
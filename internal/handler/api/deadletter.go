@@ -0,0 +1,50 @@
+package api
+
+import (
+	"we-connect-test/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DeadLetterIndex(s *queue.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := queue.GetDeadLetterListParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.GetDeadLetterList(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func ShowDeadLetter(s *queue.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := queue.GetDeadLetterParams{ID: c.Param("id")}
+		resp, statusCode := s.GetDeadLetter(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func FixDeadLetter(s *queue.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := queue.FixDeadLetterParams{}
+		err := c.ShouldBindJSON(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		p.ID = c.Param("id")
+		resp, statusCode := s.FixDeadLetter(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func RetryDeadLetter(s *queue.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := queue.RetryDeadLetterParams{ID: c.Param("id")}
+		resp, statusCode := s.RetryDeadLetter(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"path"
 	"we-connect-test/internal/financial"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +13,7 @@ func FinancialIndex(s *financial.Service) gin.HandlerFunc {
 		p := financial.GetFinancialDataListParams{}
 		err := c.ShouldBindQuery(&p)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			abortWithBindingError(c, err)
 			return
 		}
 		resp, statusCode := s.GetFinancialDataList(c, p)
@@ -20,41 +21,225 @@ func FinancialIndex(s *financial.Service) gin.HandlerFunc {
 	}
 }
 
+func SearchFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.SearchFinancialDataParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.SearchFinancialData(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func AggregateFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.AggregateFinancialDataParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.AggregateFinancialData(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func FinancialFacets(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.GetFinancialFacetsParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.GetFinancialFacets(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func BulkFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.BulkFinancialDataParams{}
+		err := bindJSON(c, &p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.BulkFinancialData(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func DeletedFinancialIndex(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.GetDeletedFinancialDataListParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.GetDeletedFinancialDataList(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func RestoreFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.RestoreFinancialDataParams{ID: c.Param("id")}
+		resp, statusCode := s.RestoreFinancialData(c, p)
+		setETag(c, resp)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func ShowFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.GetFinancialDataParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		p.ID = c.Param("id")
+		resp, statusCode := s.GetFinancialData(c, p)
+		setETag(c, resp)
+		c.JSON(statusCode, resp)
+	}
+}
+
 func CreateFinancialData(s *financial.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		p := financial.CreateFinancialDataParams{}
-		err := c.ShouldBindJSON(&p)
+		err := bindJSON(c, &p)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			abortWithBindingError(c, err)
 			return
 		}
 		resp, statusCode := s.CreateFinancialDataByUser(c, p)
-		c.JSON(statusCode, resp)
+		c.JSON(legacyStatus(statusCode), resp)
 	}
 }
 
 func UpdateFinancialData(s *financial.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		p := financial.UpdateFinancialDataParams{}
-		err := c.ShouldBindJSON(&p)
+		err := bindJSON(c, &p)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			abortWithBindingError(c, err)
 			return
 		}
+		revisions, ok := ifMatchRevisions(c)
+		if !ok {
+			return
+		}
+		p.IfRevisions = revisions
 		resp, statusCode := s.UpdateFinancialData(c, p)
-		c.JSON(statusCode, resp)
+		setETag(c, resp)
+		c.JSON(legacyStatus(statusCode), resp)
 	}
 }
 
 func DeleteFinancialData(s *financial.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		p := financial.DeleteFinancialDataParams{}
-		err := c.ShouldBindJSON(&p)
+		err := bindJSON(c, &p)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			abortWithBindingError(c, err)
 			return
 		}
+		revisions, ok := ifMatchRevisions(c)
+		if !ok {
+			return
+		}
+		p.IfRevisions = revisions
 		resp, statusCode := s.DeleteFinancialData(c, p)
+		c.JSON(legacyStatus(statusCode), resp)
+	}
+}
+
+// legacyStatus is the status the POST /create, /update and /delete routes
+// answered with before the restful routes, 200 for every success.
+func legacyStatus(statusCode int) int {
+	if statusCode == http.StatusCreated || statusCode == http.StatusNoContent {
+		return http.StatusOK
+	}
+	return statusCode
+}
+
+func StoreFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.CreateFinancialDataParams{}
+		err := bindJSON(c, &p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.CreateFinancialDataByUser(c, p)
+		if created, ok := resp.Data.(financial.CreateFinancialDataResult); ok && statusCode == http.StatusCreated {
+			c.Header("Location", path.Join(c.FullPath(), created.ID))
+		}
+		c.JSON(statusCode, resp)
+	}
+}
+
+func ReplaceFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		create := financial.CreateFinancialDataParams{}
+		err := bindJSON(c, &create)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		revisions, ok := ifMatchRevisions(c)
+		if !ok {
+			return
+		}
+		p := financial.ReplaceFinancialDataParams{
+			ID:                        c.Param("id"),
+			IfRevisions:               revisions,
+			CreateFinancialDataParams: create,
+		}
+		resp, statusCode := s.ReplaceFinancialData(c, p)
+		setETag(c, resp)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func PatchFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.UpdateFinancialDataParams{}
+		err := bindJSON(c, &p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		revisions, ok := ifMatchRevisions(c)
+		if !ok {
+			return
+		}
+		p.ID = c.Param("id")
+		p.IfRevisions = revisions
+		resp, statusCode := s.UpdateFinancialData(c, p)
+		setETag(c, resp)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func DestroyFinancialData(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revisions, ok := ifMatchRevisions(c)
+		if !ok {
+			return
+		}
+		p := financial.DeleteFinancialDataParams{ID: c.Param("id"), IfRevisions: revisions}
+		resp, statusCode := s.DeleteFinancialData(c, p)
+		if statusCode == http.StatusNoContent {
+			c.Status(statusCode)
+			return
+		}
 		c.JSON(statusCode, resp)
 	}
 }
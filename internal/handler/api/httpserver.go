@@ -8,6 +8,7 @@ import (
 	"time"
 	"we-connect-test/config"
 	"we-connect-test/internal/financial"
+	"we-connect-test/internal/queue"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -33,6 +34,7 @@ type HttpServer struct {
 type Services struct {
 	Cfg              *config.Cfg
 	FinancialService *financial.Service
+	QueueService     *queue.Service
 }
 
 func (s *HttpServer) ListenAndServe(address string) error {
@@ -45,6 +47,10 @@ func (s *HttpServer) GetEngine() http.Handler {
 }
 
 func NewHttpServer(services Services, logger *zap.Logger) *HttpServer {
+	err := registerValidations()
+	if err != nil {
+		logger.Fatal("cannot register validations", zap.Error(err))
+	}
 	apiRouter := gin.New()
 	apiRouter.Use(globalRecover(logger, services.Cfg))
 	env := services.Cfg.GetEnv()
@@ -71,13 +77,49 @@ func (s *HttpServer) registerRoutes() {
 	r := s.engine
 	v1 := r.Group("/api/v1")
 	{
-		financialRoutes := v1.Group("/financial")
+		maxBodyBytes := int64(s.services.Cfg.GetInt("http.maxBodyBytes"))
+		if maxBodyBytes <= 0 {
+			maxBodyBytes = DefaultMaxBodyBytes
+		}
+		financialRoutes := v1.Group("/financial", limitBodySize(maxBodyBytes))
 		{
 			financialRoutes.GET("", FinancialIndex(s.services.FinancialService))
+			financialRoutes.GET("/search", SearchFinancialData(s.services.FinancialService))
+			financialRoutes.GET("/aggregate", AggregateFinancialData(s.services.FinancialService))
+			financialRoutes.GET("/facets", FinancialFacets(s.services.FinancialService))
+			financialRoutes.GET("/deleted", DeletedFinancialIndex(s.services.FinancialService))
+			financialRoutes.POST("", StoreFinancialData(s.services.FinancialService))
+			financialRoutes.POST("/bulk", BulkFinancialData(s.services.FinancialService))
+			financialRoutes.GET("/:id", ShowFinancialData(s.services.FinancialService))
+			financialRoutes.PUT("/:id", ReplaceFinancialData(s.services.FinancialService))
+			financialRoutes.PATCH("/:id", PatchFinancialData(s.services.FinancialService))
+			financialRoutes.DELETE("/:id", DestroyFinancialData(s.services.FinancialService))
+			financialRoutes.POST("/:id/restore", RestoreFinancialData(s.services.FinancialService))
+			//legacy routes, kept until clients move to the ones above
 			financialRoutes.POST("/create", CreateFinancialData(s.services.FinancialService))
 			financialRoutes.POST("/update", UpdateFinancialData(s.services.FinancialService))
 			financialRoutes.POST("/delete", DeleteFinancialData(s.services.FinancialService))
 		}
+		seriesRoutes := v1.Group("/series")
+		{
+			seriesRoutes.GET("", SeriesIndex(s.services.FinancialService))
+			seriesRoutes.GET("/changes", SeriesChanges(s.services.FinancialService))
+			seriesRoutes.GET("/:ref/observations", SeriesObservations(s.services.FinancialService))
+			seriesRoutes.GET("/:ref/changes", SeriesChanges(s.services.FinancialService))
+		}
+		importRoutes := v1.Group("/imports")
+		{
+			importRoutes.POST("", CreateImport(s.services.QueueService))
+			importRoutes.GET("/:id", ShowImport(s.services.QueueService))
+			importRoutes.POST("/:id/cancel", CancelImport(s.services.QueueService))
+		}
+		deadLetterRoutes := v1.Group("/deadletters")
+		{
+			deadLetterRoutes.GET("", DeadLetterIndex(s.services.QueueService))
+			deadLetterRoutes.GET("/:id", ShowDeadLetter(s.services.QueueService))
+			deadLetterRoutes.PATCH("/:id", FixDeadLetter(s.services.QueueService))
+			deadLetterRoutes.POST("/:id/retry", RetryDeadLetter(s.services.QueueService))
+		}
 	}
 }
 
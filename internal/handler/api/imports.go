@@ -0,0 +1,36 @@
+package api
+
+import (
+	"we-connect-test/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreateImport(s *queue.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := queue.CreateImportParams{}
+		err := c.ShouldBind(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.CreateImport(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func ShowImport(s *queue.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := queue.GetImportParams{ID: c.Param("id")}
+		resp, statusCode := s.GetImport(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func CancelImport(s *queue.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := queue.CancelImportParams{ID: c.Param("id")}
+		resp, statusCode := s.CancelImport(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"we-connect-test/internal/financial"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SeriesIndex(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.GetSeriesListParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		resp, statusCode := s.GetSeriesList(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func SeriesObservations(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.GetSeriesObservationsParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		p.SeriesReference = c.Param("ref")
+		resp, statusCode := s.GetSeriesObservations(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
+
+func SeriesChanges(s *financial.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := financial.GetSeriesChangesParams{}
+		err := c.ShouldBindQuery(&p)
+		if err != nil {
+			abortWithBindingError(c, err)
+			return
+		}
+		p.SeriesReference = c.Param("ref")
+		resp, statusCode := s.GetSeriesChanges(c, p)
+		c.JSON(statusCode, resp)
+	}
+}
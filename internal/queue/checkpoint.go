@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// checkpointEvery is how many newly committed lines trigger a checkpoint save.
+const checkpointEvery = 500
+
+// lineTracker keeps the highest line number below which every line has been
+// processed. Workers finish lines out of order, so only the contiguous prefix
+// is safe to record as committed.
+type lineTracker struct {
+	committed int
+	pending   map[int]bool
+}
+
+func (t *lineTracker) done(line int) {
+	if line <= t.committed {
+		return
+	}
+	t.pending[line] = true
+	for t.pending[t.committed+1] {
+		delete(t.pending, t.committed+1)
+		t.committed++
+	}
+}
+
+func newLineTracker(committed int) *lineTracker {
+	return &lineTracker{
+		committed: committed,
+		pending:   make(map[int]bool),
+	}
+}
+
+func fileFingerprint(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,285 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"we-connect-test/internal/response"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultUploadDir       = "./uploads"
+	defaultWorkerCount     = 5
+	importProgressInterval = time.Second
+)
+
+type CreateImportParams struct {
+	File    *multipart.FileHeader `form:"file"`
+	Profile string                `form:"profile"`
+}
+
+type GetImportParams struct {
+	ID string `json:"-"`
+}
+
+type CancelImportParams struct {
+	ID string `json:"-"`
+}
+
+type SingleImportResult struct {
+	ID         string     `json:"id"`
+	FileName   string     `json:"fileName"`
+	Profile    string     `json:"profile"`
+	Status     string     `json:"status"`
+	RowsRead   int64      `json:"rowsRead"`
+	Inserted   int64      `json:"inserted"`
+	Failed     int64      `json:"failed"`
+	Skipped    int64      `json:"skipped"`
+	Error      string     `json:"error"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// CreateImport stores the uploaded file and imports it in the background
+// with a Manager. The returned id is used to follow or cancel the import.
+func (s *Service) CreateImport(
+	ctx context.Context,
+	params CreateImportParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if params.File == nil {
+		return response.Error("file is required", http.StatusBadRequest, nil)
+	}
+	if params.Profile != "" {
+		if _, ok := s.profiles[strings.ToLower(params.Profile)]; !ok {
+			return response.Error("unknown mapping profile", http.StatusBadRequest, nil)
+		}
+	}
+	job := ImportJobModel{
+		ID:       primitive.NewObjectID(),
+		FileName: filepath.Base(params.File.Filename),
+		Profile:  params.Profile,
+		Status:   ImportStatusPending,
+	}
+	job.FilePath = filepath.Join(s.uploadDir, job.ID.Hex()+filepath.Ext(job.FileName))
+	err := saveUpload(params.File, job.FilePath)
+	if err != nil {
+		s.logger.Error("cannot save uploaded file",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "CreateImport"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	err = s.repo.CreateImportJob(ctx, job)
+	if err != nil {
+		s.logger.Error("cannot CreateImportJob",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "CreateImport"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+
+	//the import outlives the request, so it does not use the request context
+	importCtx, cancel := context.WithCancel(context.Background())
+	s.runningMu.Lock()
+	s.running[job.ID.Hex()] = cancel
+	s.runningMu.Unlock()
+	go s.runImport(importCtx, job)
+
+	res := make(map[string]string)
+	res["id"] = job.ID.Hex()
+	apiResponse, _ = response.Success(res, "")
+	return apiResponse, http.StatusAccepted
+}
+
+func (s *Service) GetImport(
+	ctx context.Context,
+	params GetImportParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if !primitive.IsValidObjectID(params.ID) {
+		return response.Error("invalid id", http.StatusBadRequest, nil)
+	}
+	job, err := s.repo.GetImportJobByID(ctx, params.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return response.Error("not found", http.StatusNotFound, nil)
+	}
+	if err != nil {
+		s.logger.Error("cannot GetImportJobByID",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "GetImport"),
+			zap.String("id", params.ID),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	return response.Success(toSingleImportResult(job), "")
+}
+
+// CancelImport stops a running import. Rows already written stay stored and
+// the file checkpoint lets a later upload of the same file resume.
+func (s *Service) CancelImport(
+	ctx context.Context,
+	params CancelImportParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if !primitive.IsValidObjectID(params.ID) {
+		return response.Error("invalid id", http.StatusBadRequest, nil)
+	}
+	s.runningMu.Lock()
+	cancel, ok := s.running[params.ID]
+	s.runningMu.Unlock()
+	if ok {
+		cancel()
+		res := make(map[string]string)
+		apiResponse, _ = response.Success(res, "import is being cancelled")
+		return apiResponse, http.StatusAccepted
+	}
+	_, err := s.repo.GetImportJobByID(ctx, params.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return response.Error("not found", http.StatusNotFound, nil)
+	}
+	if err != nil {
+		s.logger.Error("cannot GetImportJobByID",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "CancelImport"),
+			zap.String("id", params.ID),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	return response.Error("import is not running", http.StatusConflict, nil)
+}
+
+// MarkInterruptedImports flags imports left pending or running by a previous
+// process.
+func (s *Service) MarkInterruptedImports(ctx context.Context) error {
+	count, err := s.repo.MarkInterruptedImportJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		s.logger.Warn("marked interrupted imports", zap.Int64("count", count))
+	}
+	return nil
+}
+
+func (s *Service) runImport(ctx context.Context, job ImportJobModel) {
+	defer func() {
+		s.runningMu.Lock()
+		cancel := s.running[job.ID.Hex()]
+		delete(s.running, job.ID.Hex())
+		s.runningMu.Unlock()
+		cancel()
+	}()
+	manager := NewManager(s.cfg, s.financialService, s.repo, s.logger)
+	//the profile is validated when the import is created
+	_ = manager.SetProfile(job.Profile)
+
+	startedAt := time.Now().UTC()
+	job.Status = ImportStatusRunning
+	job.StartedAt = &startedAt
+	s.saveImportJob(job)
+
+	runErr := make(chan error, 1)
+	go func() {
+		_, err := manager.Run(ctx, job.FilePath, s.workerCount)
+		runErr <- err
+	}()
+	ticker := time.NewTicker(importProgressInterval)
+	defer ticker.Stop()
+	var err error
+	for finished := false; !finished; {
+		select {
+		case err = <-runErr:
+			finished = true
+		case <-ticker.C:
+			job.setProgress(manager.Progress())
+			s.saveImportJob(job)
+		}
+	}
+
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	job.setProgress(manager.Progress())
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.Status = ImportStatusCancelled
+	case err != nil:
+		job.Status = ImportStatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = ImportStatusCompleted
+	}
+	s.saveImportJob(job)
+}
+
+// saveImportJob runs detached from the import context, which is already done
+// when a cancelled import stores its final state.
+func (s *Service) saveImportJob(job ImportJobModel) {
+	err := s.repo.UpdateImportJob(context.Background(), job)
+	if err != nil {
+		s.logger.Error("cannot UpdateImportJob",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "runImport"),
+			zap.String("id", job.ID.Hex()),
+		)
+	}
+}
+
+func (m *ImportJobModel) setProgress(p Progress) {
+	m.RowsRead = p.RowsRead
+	m.Inserted = p.Inserted
+	m.Failed = p.Failed
+	m.Skipped = p.Skipped
+}
+
+func saveUpload(fileHeader *multipart.FileHeader, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	src, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func toSingleImportResult(m ImportJobModel) SingleImportResult {
+	return SingleImportResult{
+		ID:         m.ID.Hex(),
+		FileName:   m.FileName,
+		Profile:    m.Profile,
+		Status:     m.Status,
+		RowsRead:   m.RowsRead,
+		Inserted:   m.Inserted,
+		Failed:     m.Failed,
+		Skipped:    m.Skipped,
+		Error:      m.Error,
+		CreatedAt:  m.CreatedAt,
+		StartedAt:  m.StartedAt,
+		FinishedAt: m.FinishedAt,
+	}
+}
@@ -1,43 +1,76 @@
 package queue_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 	"we-connect-test/internal/di"
 	"we-connect-test/internal/financial"
+	"we-connect-test/internal/handler/api"
 	"we-connect-test/internal/queue"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func TestManager_Run(t *testing.T) {
-	//we do this to be sure that when tests run concurrently
-	//the db data changes in other tests does not affect this one
-	time.Sleep(3 * time.Second)
+// TestMain gives the package a database of its own, so the tests of other
+// packages running at the same time do not empty its collections.
+func TestMain(m *testing.M) {
+	os.Setenv("PROJECT_MONGODB_DBNAME", "weConnectDb_test_queue")
+	os.Exit(m.Run())
+}
+
+// setup returns a new container and its database with the collections the
+// tests write to emptied.
+func setup(t *testing.T) (*di.Container, *mongo.Database) {
 	container := di.NewContainer()
-	cfg := container.GetCfg()
-	dbName := cfg.GetString("mongodb.dbname")
 	mongoDBClient, err := container.GetMongoDBClient()
-	assert.Nil(t, err)
-	//first we empty the db collection
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	db := mongoDBClient.Database(container.GetCfg().GetString("mongodb.dbname"))
+	for _, name := range []string{"financialData", "importCheckpoints", "importDeadLetters", "importJobs"} {
+		_, err = db.Collection(name).DeleteMany(context.Background(), bson.M{})
+		assert.Nil(t, err)
+	}
+	return container, db
+}
+
+func TestManager_Run(t *testing.T) {
+	container, db := setup(t)
+	cfg := container.GetCfg()
 	ctx := context.Background()
-	coll := mongoDBClient.Database(dbName).Collection("financialData")
-	_, err = coll.DeleteMany(ctx, bson.M{})
-	assert.Nil(t, err)
+	coll := db.Collection("financialData")
 
 	logger, err := container.GetLogger()
 	assert.Nil(t, err)
 	financialService := container.GetFinancialService()
-	manager := queue.NewManager(financialService, logger)
+	manager := queue.NewManager(cfg, financialService, container.GetQueueRepository(), logger)
 	filePath := "./data_test.csv"
-	err = manager.Run(ctx, filePath, 5)
+	summary, err := manager.Run(ctx, filePath, 5)
 	assert.Nil(t, err)
+	assert.Equal(t, summary.Inserted, int64(10))
+	assert.Empty(t, summary.FailedLines)
+	assert.Positive(t, summary.Duration)
+	//a manager only runs once, its channels are closed
+	_, err = manager.Run(ctx, filePath, 5)
+	assert.ErrorIs(t, err, queue.ErrManagerUsed)
 
-	//sleep here so all goroutine finish their job
-	time.Sleep(5 * time.Second)
-	coll = mongoDBClient.Database(dbName).Collection("financialData")
+	coll = db.Collection("financialData")
 	cursor, err := coll.Find(ctx, bson.M{})
 	assert.Nil(t, err)
 	var results []financial.FinancialModel
@@ -58,4 +91,502 @@ func TestManager_Run(t *testing.T) {
 		assert.NotEmpty(t, res.SeriesTitle3)
 		assert.NotEmpty(t, res.SeriesTitle4)
 	}
+
+	//importing the file again skips the rows of deleted records
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": results[0].ID}, bson.M{"$set": bson.M{"deletedAt": time.Now()}})
+	assert.Nil(t, err)
+	_, err = db.Collection("importCheckpoints").DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+	summary, err = queue.NewManager(cfg, financialService, container.GetQueueRepository(), logger).Run(ctx, filePath, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Inserted, int64(9))
+	assert.Equal(t, summary.Skipped, int64(1))
+	assert.Empty(t, summary.FailedLines)
+	m := financial.FinancialModel{}
+	err = coll.FindOne(ctx, bson.M{"_id": results[0].ID}).Decode(&m)
+	assert.Nil(t, err)
+	assert.NotNil(t, m.DeletedAt)
+	deadLetters, err := db.Collection("importDeadLetters").CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, deadLetters, int64(0))
+}
+
+func TestManager_Run_Resume(t *testing.T) {
+	container, db := setup(t)
+	cfg := container.GetCfg()
+	ctx := context.Background()
+	coll := db.Collection("financialData")
+	checkpointColl := db.Collection("importCheckpoints")
+
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	financialService := container.GetFinancialService()
+	queueRepo := container.GetQueueRepository()
+	filePath := "./data_test.csv"
+	_, err = queue.NewManager(cfg, financialService, queueRepo, logger).Run(ctx, filePath, 5)
+	assert.Nil(t, err)
+
+	//the whole file is committed, header is line 1 and there are 10 records
+	checkpoint := queue.CheckpointModel{}
+	err = checkpointColl.FindOne(ctx, bson.M{"filePath": filePath}).Decode(&checkpoint)
+	assert.Nil(t, err)
+	assert.True(t, checkpoint.Completed)
+	assert.Equal(t, checkpoint.LastCommittedLine, 11)
+	assert.NotEmpty(t, checkpoint.Fingerprint)
+
+	//a completed file is skipped
+	_, err = coll.DeleteMany(ctx, bson.M{})
+	assert.Nil(t, err)
+	summary, err := queue.NewManager(cfg, financialService, queueRepo, logger).Run(ctx, filePath, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Inserted, int64(0))
+	assert.Equal(t, summary.Skipped, int64(10))
+	count, err := coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(0))
+
+	//an interrupted file resumes after the last committed line
+	checkpoint.Completed = false
+	checkpoint.LastCommittedLine = 6
+	err = queueRepo.SaveCheckpoint(ctx, checkpoint)
+	assert.Nil(t, err)
+	summary, err = queue.NewManager(cfg, financialService, queueRepo, logger).Run(ctx, filePath, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Inserted, int64(5))
+	count, err = coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(5))
+	err = checkpointColl.FindOne(ctx, bson.M{"_id": checkpoint.Fingerprint}).Decode(&checkpoint)
+	assert.Nil(t, err)
+	assert.True(t, checkpoint.Completed)
+	assert.Equal(t, checkpoint.LastCommittedLine, 11)
+}
+
+func TestManager_Run_Cancelled(t *testing.T) {
+	container, db := setup(t)
+	cfg := container.GetCfg()
+	//small batches so the import is cancelled between writes
+	cfg.Set("queue.batchSize", 50)
+	ctx := context.Background()
+	coll := db.Collection("financialData")
+	checkpointColl := db.Collection("importCheckpoints")
+
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	financialService := container.GetFinancialService()
+	queueRepo := container.GetQueueRepository()
+
+	//a file large enough to still be importing when it is cancelled
+	csvData, err := os.ReadFile("./data_test.csv")
+	assert.Nil(t, err)
+	records, err := csv.NewReader(bytes.NewReader(csvData)).ReadAll()
+	assert.Nil(t, err)
+	const rows = 20000
+	filePath := filepath.Join(t.TempDir(), "large.csv")
+	file, err := os.Create(filePath)
+	assert.Nil(t, err)
+	writer := csv.NewWriter(file)
+	assert.Nil(t, writer.Write(records[0]))
+	for i := 0; i < rows; i++ {
+		record := append([]string{}, records[1]...)
+		record[0] = fmt.Sprintf("SR%05d", i)
+		assert.Nil(t, writer.Write(record))
+	}
+	writer.Flush()
+	assert.Nil(t, writer.Error())
+	assert.Nil(t, file.Close())
+
+	//cancel once the first rows are written. One worker writes the lines in
+	//order, so every written line is also committed
+	manager := queue.NewManager(cfg, financialService, queueRepo, logger)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		for runCtx.Err() == nil && manager.Progress().Inserted == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	type runResult struct {
+		summary queue.Summary
+		err     error
+	}
+	done := make(chan runResult)
+	go func() {
+		summary, err := manager.Run(runCtx, filePath, 1)
+		done <- runResult{summary, err}
+	}()
+	var cancelled runResult
+	select {
+	case cancelled = <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("Run did not return after the cancellation")
+	}
+	assert.ErrorIs(t, cancelled.err, context.Canceled)
+	assert.Empty(t, cancelled.summary.FailedLines)
+	assert.Positive(t, cancelled.summary.Inserted)
+	assert.Less(t, cancelled.summary.Inserted, int64(rows))
+
+	//the checkpoint holds the committed prefix, which is in the db
+	checkpoints, err := checkpointColl.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, checkpoints, int64(1))
+	checkpoint := queue.CheckpointModel{}
+	err = checkpointColl.FindOne(ctx, bson.M{}).Decode(&checkpoint)
+	assert.Nil(t, err)
+	assert.False(t, checkpoint.Completed)
+	assert.Greater(t, checkpoint.LastCommittedLine, 1)
+	committed := int64(checkpoint.LastCommittedLine - 1)
+	assert.Equal(t, committed, cancelled.summary.Inserted)
+	count, err := coll.CountDocuments(ctx, bson.M{"seriesReference": bson.M{"$lte": fmt.Sprintf("SR%05d", committed-1)}})
+	assert.Nil(t, err)
+	assert.Equal(t, count, committed)
+
+	//the next run skips the committed prefix and imports the rest
+	next, err := queue.NewManager(cfg, financialService, queueRepo, logger).Run(ctx, filePath, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, next.Skipped, committed)
+	assert.Equal(t, next.Inserted, int64(rows)-committed)
+	count, err = coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(rows))
+}
+
+func TestManager_Run_Formats(t *testing.T) {
+	container, db := setup(t)
+	cfg := container.GetCfg()
+	ctx := context.Background()
+	coll := db.Collection("financialData")
+	checkpointColl := db.Collection("importCheckpoints")
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	financialService := container.GetFinancialService()
+	queueRepo := container.GetQueueRepository()
+
+	csvData, err := os.ReadFile("./data_test.csv")
+	assert.Nil(t, err)
+	records, err := csv.NewReader(bytes.NewReader(csvData)).ReadAll()
+	assert.Nil(t, err)
+	tsvData := bytes.Buffer{}
+	tsvWriter := csv.NewWriter(&tsvData)
+	tsvWriter.Comma = '\t'
+	err = tsvWriter.WriteAll(records)
+	assert.Nil(t, err)
+	jsonData := bytes.Buffer{}
+	for _, record := range records[1:] {
+		object := make(map[string]string)
+		for i, column := range records[0] {
+			object[column] = record[i]
+		}
+		line, err := json.Marshal(object)
+		assert.Nil(t, err)
+		jsonData.Write(line)
+		jsonData.WriteString("\n")
+	}
+	gzData := bytes.Buffer{}
+	gzWriter := gzip.NewWriter(&gzData)
+	_, err = gzWriter.Write(csvData)
+	assert.Nil(t, err)
+	assert.Nil(t, gzWriter.Close())
+	zipData := bytes.Buffer{}
+	zipWriter := zip.NewWriter(&zipData)
+	entry, err := zipWriter.Create("data.jsonl")
+	assert.Nil(t, err)
+	_, err = entry.Write(jsonData.Bytes())
+	assert.Nil(t, err)
+	assert.Nil(t, zipWriter.Close())
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"data.tsv":    tsvData.Bytes(),
+		"data.jsonl":  jsonData.Bytes(),
+		"data.csv.gz": gzData.Bytes(),
+		"data.zip":    zipData.Bytes(),
+		//no extension, the format is detected from the content
+		"data_tsv": tsvData.Bytes(),
+		"data_gz":  gzData.Bytes(),
+	}
+	for name, data := range files {
+		_, err = coll.DeleteMany(ctx, bson.M{})
+		assert.Nil(t, err)
+		_, err = checkpointColl.DeleteMany(ctx, bson.M{})
+		assert.Nil(t, err)
+		filePath := filepath.Join(dir, name)
+		err = os.WriteFile(filePath, data, 0o644)
+		assert.Nil(t, err)
+		summary, err := queue.NewManager(cfg, financialService, queueRepo, logger).Run(ctx, filePath, 2)
+		assert.Nil(t, err, name)
+		assert.Equal(t, summary.Inserted, int64(10), name)
+		assert.Empty(t, summary.FailedLines, name)
+		count, err := coll.CountDocuments(ctx, bson.M{"units": "Dollars", "magnitude": 6})
+		assert.Nil(t, err)
+		assert.Equal(t, count, int64(10), name)
+	}
+}
+
+func TestManager_Run_HeaderMapping(t *testing.T) {
+	container, db := setup(t)
+	cfg := container.GetCfg()
+	ctx := context.Background()
+	coll := db.Collection("financialData")
+
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	financialService := container.GetFinancialService()
+	manager := queue.NewManager(cfg, financialService, container.GetQueueRepository(), logger)
+	err = manager.SetProfile("unknown")
+	assert.NotNil(t, err)
+	//columns are reordered, there is an extra column and two malformed rows
+	summary, err := manager.Run(ctx, "./data_reordered_test.csv", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Inserted, int64(4))
+	assert.Equal(t, summary.FailedLines, []int{4, 5})
+
+	cursor, err := coll.Find(ctx, bson.M{})
+	assert.Nil(t, err)
+	var results []financial.FinancialModel
+	err = cursor.All(ctx, &results)
+	assert.Nil(t, err)
+	assert.Equal(t, len(results), 4)
+	for _, res := range results {
+		assert.Equal(t, res.SeriesReference, "BDCQ.SF1AA2CA")
+		assert.Equal(t, res.Units, "Dollars")
+		assert.Equal(t, res.Magnitude, 6)
+		assert.Equal(t, res.SeriesTitle2, "Forestry and Logging")
+		assert.Empty(t, res.Suppressed)
+	}
+}
+
+func TestWatcher_Archive_Reject(t *testing.T) {
+	container, _ := setup(t)
+	cfg := container.GetCfg()
+	ctx := context.Background()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	cfg.Set("queue.watch.dir", dir)
+	cfg.Set("queue.watch.interval", "50ms")
+	watcher := queue.NewWatcher(cfg, container.GetFinancialService(), container.GetQueueRepository(), logger)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- watcher.Start(watchCtx)
+	}()
+
+	for name, src := range map[string]string{"good.csv": "./data_test.csv", "bad.csv": "./data_reordered_test.csv"} {
+		data, err := os.ReadFile(src)
+		assert.Nil(t, err)
+		err = os.WriteFile(filepath.Join(dir, name), data, 0o644)
+		assert.Nil(t, err)
+	}
+	exists := func(path string) func() bool {
+		return func() bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}
+	}
+	assert.Eventually(t, exists(filepath.Join(dir, "archive", "good.csv")), 10*time.Second, 50*time.Millisecond)
+	assert.Eventually(t, exists(filepath.Join(dir, "rejected", "bad.csv.error.json")), 10*time.Second, 50*time.Millisecond)
+	assert.NoFileExists(t, filepath.Join(dir, "good.csv"))
+	assert.NoFileExists(t, filepath.Join(dir, "bad.csv"))
+	assert.FileExists(t, filepath.Join(dir, "rejected", "bad.csv"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "rejected", "bad.csv.error.json"))
+	assert.Nil(t, err)
+	report := queue.Report{}
+	err = json.Unmarshal(data, &report)
+	assert.Nil(t, err)
+	assert.Equal(t, report.File, "bad.csv")
+	assert.Equal(t, report.Inserted, int64(4))
+	assert.Equal(t, report.FailedLines, []int{4, 5})
+	assert.NotEmpty(t, report.Error)
+
+	cancel()
+	assert.Nil(t, <-watchErr)
+}
+
+func TestDeadLetter_Fix_Retry(t *testing.T) {
+	container, db := setup(t)
+	cfg := container.GetCfg()
+	ctx := context.Background()
+
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	financialService := container.GetFinancialService()
+	queueService := container.GetQueueService()
+	err = queueService.EnsureIndexes(ctx)
+	assert.Nil(t, err)
+	manager := queue.NewManager(cfg, financialService, container.GetQueueRepository(), logger)
+	_, err = manager.Run(ctx, "./data_reordered_test.csv", 2)
+	assert.Nil(t, err)
+
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: financialService,
+		QueueService:     queueService,
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	//the short row and the row with an empty period are dead letters
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/deadletters?sourceFile=./data_reordered_test.csv", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	result := struct {
+		Status  bool
+		Message string
+		Data    []queue.SingleDeadLetterResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &result)
+	assert.Nil(t, err)
+	assert.Equal(t, len(result.Data), 2)
+	var shortRow queue.SingleDeadLetterResult
+	for _, dl := range result.Data {
+		assert.Equal(t, dl.Attempts, 1)
+		assert.NotEmpty(t, dl.Error)
+		assert.Equal(t, len(dl.Header), 13)
+		if dl.LineNumber == 4 {
+			shortRow = dl
+		}
+	}
+	assert.Equal(t, shortRow.Record, []string{"2016.12", "BDCQ.SF1AA2CA", "short row"})
+
+	//retrying without a fix fails again and counts the attempt
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/deadletters/"+shortRow.ID+"/retry", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusUnprocessableEntity)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/deadletters/"+shortRow.ID, nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	single := struct {
+		Status  bool
+		Message string
+		Data    queue.SingleDeadLetterResult
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &single)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Data.Attempts, 2)
+
+	//a fix must match the header
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/deadletters/"+shortRow.ID, bytes.NewReader([]byte(`{"record":["2016.12"]}`)))
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+
+	fixed := `{"record":["2016.12","BDCQ.SF1AA2CA","fixed","1054.408","F","Dollars","6",` +
+		`"Business Data Collection - BDC","Industry by financial variable (NZSIOC Level 2)",` +
+		`"Sales (operating income)","Forestry and Logging","Current prices","Unadjusted"]}`
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/deadletters/"+shortRow.ID, bytes.NewReader([]byte(fixed)))
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/deadletters/"+shortRow.ID+"/retry", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+
+	count, err := db.Collection("financialData").CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(5))
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/deadletters/"+shortRow.ID, nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusNotFound)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/deadletters/invalid", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
+}
+
+func TestImport_Create_Show_Cancel(t *testing.T) {
+	container, db := setup(t)
+	cfg := container.GetCfg()
+	ctx := context.Background()
+	logger, err := container.GetLogger()
+	assert.Nil(t, err)
+	httpServer := api.NewHttpServer(api.Services{
+		Cfg:              cfg,
+		FinancialService: container.GetFinancialService(),
+		QueueService:     container.GetQueueService(),
+	}, logger)
+	engine := httpServer.GetEngine()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "data_test.csv")
+	assert.Nil(t, err)
+	f, err := os.Open("./data_test.csv")
+	assert.Nil(t, err)
+	_, err = io.Copy(part, f)
+	assert.Nil(t, err)
+	f.Close()
+	err = writer.Close()
+	assert.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/imports", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusAccepted)
+	created := struct {
+		Status  bool
+		Message string
+		Data    map[string]string
+	}{}
+	err = json.Unmarshal(res.Body.Bytes(), &created)
+	assert.Nil(t, err)
+	id := created.Data["id"]
+	assert.NotEmpty(t, id)
+
+	//wait for the background import to finish
+	result := struct {
+		Status  bool
+		Message string
+		Data    queue.SingleImportResult
+	}{}
+	for i := 0; i < 50; i++ {
+		res = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/imports/"+id, nil)
+		engine.ServeHTTP(res, req)
+		assert.Equal(t, res.Code, http.StatusOK)
+		err = json.Unmarshal(res.Body.Bytes(), &result)
+		assert.Nil(t, err)
+		if result.Data.Status == queue.ImportStatusCompleted {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	assert.Equal(t, result.Data.Status, queue.ImportStatusCompleted)
+	assert.Equal(t, result.Data.FileName, "data_test.csv")
+	assert.Equal(t, result.Data.RowsRead, int64(10))
+	assert.Equal(t, result.Data.Inserted, int64(10))
+	assert.Equal(t, result.Data.Failed, int64(0))
+	assert.Equal(t, result.Data.Skipped, int64(0))
+	assert.NotNil(t, result.Data.FinishedAt)
+	count, err := db.Collection("financialData").CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(10))
+
+	//a finished import can not be cancelled
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/imports/"+id+"/cancel", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusConflict)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/imports/"+primitive.NewObjectID().Hex(), nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusNotFound)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/imports", nil)
+	engine.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusBadRequest)
 }
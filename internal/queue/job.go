@@ -1,7 +1,10 @@
 package queue
 
+import "we-connect-test/internal/financial"
+
 type Job struct {
 	LineNumber      int
+	Record          []string
 	SeriesReference string
 	Period          string
 	DataValue       string
@@ -18,6 +21,37 @@ type Job struct {
 	SeriesTitle5    string
 }
 
+func (j *Job) toFinancialModel() (financial.FinancialModel, error) {
+	period, err := financial.ParsePeriod(j.Period)
+	if err != nil {
+		return financial.FinancialModel{}, err
+	}
+	dataValue, err := financial.ParseDataValue(j.DataValue)
+	if err != nil {
+		return financial.FinancialModel{}, err
+	}
+	magnitude, err := financial.ParseMagnitude(j.Magnitude)
+	if err != nil {
+		return financial.FinancialModel{}, err
+	}
+	return financial.FinancialModel{
+		SeriesReference: j.SeriesReference,
+		Period:          period,
+		DataValue:       dataValue,
+		Suppressed:      j.Suppressed,
+		Status:          j.Status,
+		Units:           j.Units,
+		Magnitude:       magnitude,
+		Subject:         j.Subject,
+		Group:           j.Group,
+		SeriesTitle1:    j.SeriesTitle1,
+		SeriesTitle2:    j.SeriesTitle2,
+		SeriesTitle3:    j.SeriesTitle3,
+		SeriesTitle4:    j.SeriesTitle4,
+		SeriesTitle5:    j.SeriesTitle5,
+	}, nil
+}
+
 func newJob() Job {
 	return Job{}
 }
@@ -2,107 +2,398 @@ package queue
 
 import (
 	"context"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"we-connect-test/config"
 	"we-connect-test/internal/financial"
 
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second
+)
+
+// ErrManagerUsed is returned by Run on a Manager that has already run.
+var ErrManagerUsed = errors.New("manager has already run")
+
+// Manager imports a single file. Its channels are closed once the import is
+// done, so a Manager is used for one Run and a new one is made for the next
+// file.
 type Manager struct {
+	started          atomic.Bool
 	jobCollector     chan *Job
 	errCollector     chan workerErr
+	lineCollector    chan int
 	workers          []*worker
 	financialService *financial.Service
+	repo             *Repository
 	logger           *zap.Logger
-	quit             chan bool
+	batchSize        int
+	flushInterval    time.Duration
+	profiles         map[string]Profile
+	profile          string
+	// delimiter overrides the separator of delimited files, 0 means the
+	// default of the file format.
+	delimiter   rune
+	source      source
+	counters    counters
+	failedLines []int
+	// lastLine is set by the dispatcher once the whole file has been read.
+	lastLine int
+}
+
+// Summary is the outcome of a finished Run.
+type Summary struct {
+	Inserted    int64
+	FailedLines []int
+	Skipped     int64
+	Duration    time.Duration
+}
+
+// Progress is a snapshot of the counters of a running import.
+type Progress struct {
+	RowsRead int64
+	Inserted int64
+	Failed   int64
+	Skipped  int64
+}
+
+type counters struct {
+	read      atomic.Int64
+	processed atomic.Int64
+	failed    atomic.Int64
+	skipped   atomic.Int64
+	// deleted counts the processed rows of deleted records, which are
+	// skipped instead of restored.
+	deleted atomic.Int64
+}
+
+// source describes the file being imported. Header and Profile are set by
+// the dispatcher before the first job is sent.
+type source struct {
+	FilePath    string
+	Fingerprint string
+	Header      []string
+	Profile     string
 }
 
 type workerErr struct {
 	Err        error
 	LineNumber int
+	Record     []string
 }
 
-func (m *Manager) Run(ctx context.Context, filePath string, workerCount int) error {
+// Run imports filePath and blocks until every worker has drained. Cancelling
+// ctx stops reading the file and the workers, the rows not written by then are
+// picked up by the next import of the same file. Run returns ErrManagerUsed
+// when it is called again.
+func (m *Manager) Run(ctx context.Context, filePath string, workerCount int) (Summary, error) {
+	startedAt := time.Now()
+	if !m.started.CompareAndSwap(false, true) {
+		return Summary{}, ErrManagerUsed
+	}
+	if filePath == "" {
+		return m.summary(startedAt), fmt.Errorf("filePath is empty")
+	}
+	fingerprint, err := fileFingerprint(filePath)
+	if err != nil {
+		return m.summary(startedAt), err
+	}
+	checkpoint, err := m.repo.GetCheckpoint(ctx, fingerprint)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return m.summary(startedAt), err
+	}
+	if checkpoint.Completed {
+		m.logger.Info("file is already imported, skipping",
+			zap.String("filePath", filePath),
+			zap.String("fingerprint", fingerprint),
+		)
+		m.counters.skipped.Store(int64(checkpoint.LastCommittedLine - 1))
+		return m.summary(startedAt), nil
+	}
+	checkpoint.Fingerprint = fingerprint
+	checkpoint.FilePath = filePath
+	m.source = source{FilePath: filePath, Fingerprint: fingerprint}
+	//the first line is the header, so it is always committed
+	if checkpoint.LastCommittedLine < 1 {
+		checkpoint.LastCommittedLine = 1
+	}
+	if checkpoint.LastCommittedLine > 1 {
+		m.logger.Info("resuming file import",
+			zap.String("filePath", filePath),
+			zap.Int("lastCommittedLine", checkpoint.LastCommittedLine),
+		)
+	}
+
+	workers := sync.WaitGroup{}
 	for i := 0; i < workerCount; i++ {
-		worker := newWorker(m.jobCollector, m.errCollector, i, m.financialService)
+		worker := newWorker(
+			m.jobCollector,
+			m.errCollector,
+			m.lineCollector,
+			i,
+			m.financialService,
+			m.batchSize,
+			m.flushInterval,
+		)
 		m.workers = append(m.workers, worker)
-		go worker.start(ctx)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.start(ctx)
+		}()
+	}
+	collectors := sync.WaitGroup{}
+	collectors.Add(2)
+	go func() {
+		defer collectors.Done()
+		m.collectErrors()
+	}()
+	go func() {
+		defer collectors.Done()
+		m.collectCheckpoints(checkpoint)
+	}()
+	err = m.startDispatcher(ctx, filePath, checkpoint.LastCommittedLine)
+	//the dispatcher is done and the workers only stop after it closed the job
+	//channel, so nothing sends on the collector channels after this
+	workers.Wait()
+	close(m.lineCollector)
+	close(m.errCollector)
+	collectors.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return m.summary(startedAt), err
+}
+
+// Progress returns the counters of the import so far.
+func (m *Manager) Progress() Progress {
+	failed := m.counters.failed.Load()
+	deleted := m.counters.deleted.Load()
+	return Progress{
+		RowsRead: m.counters.read.Load(),
+		Inserted: m.counters.processed.Load() - failed - deleted,
+		Failed:   failed,
+		Skipped:  m.counters.skipped.Load() + deleted,
+	}
+}
+
+func (m *Manager) summary(startedAt time.Time) Summary {
+	sort.Ints(m.failedLines)
+	p := m.Progress()
+	return Summary{
+		Inserted:    p.Inserted,
+		FailedLines: m.failedLines,
+		Skipped:     p.Skipped,
+		Duration:    time.Since(startedAt),
 	}
-	go m.collectErrors(ctx)
-	return m.startDispatcher(ctx, filePath)
 }
 
-func (m *Manager) collectErrors(ctx context.Context) {
+// collectErrors stores every failed row in the dead letter collection so it
+// can be inspected, fixed and retried later. Rows of deleted records are
+// counted as skipped instead. Like collectCheckpoints it writes with its own
+// context, so rows collected before a cancellation are kept.
+func (m *Manager) collectErrors() {
+	ctx := context.Background()
 	for workErr := range m.errCollector {
+		if errors.Is(workErr.Err, financial.ErrDeletedFinancialData) {
+			m.counters.deleted.Add(1)
+			m.logger.Info("record is deleted, skipping",
+				zap.String("filePath", m.source.FilePath),
+				zap.Int("lineNumber", workErr.LineNumber),
+			)
+			continue
+		}
+		m.counters.failed.Add(1)
+		m.failedLines = append(m.failedLines, workErr.LineNumber)
 		m.logger.Error("worker error",
 			zap.Error(workErr.Err),
 			zap.Int("lineNumber", workErr.LineNumber),
 		)
+		err := m.repo.SaveDeadLetter(ctx, DeadLetterModel{
+			SourceFile:  m.source.FilePath,
+			Fingerprint: m.source.Fingerprint,
+			LineNumber:  workErr.LineNumber,
+			Profile:     m.source.Profile,
+			Header:      m.source.Header,
+			Record:      workErr.Record,
+			Error:       workErr.Err.Error(),
+		})
+		if err != nil {
+			m.logger.Error("cannot save dead letter",
+				zap.Error(err),
+				zap.String("filePath", m.source.FilePath),
+				zap.Int("lineNumber", workErr.LineNumber),
+			)
+		}
 	}
 }
 
-func (m *Manager) startDispatcher(ctx context.Context, filePath string) error {
-	if filePath == "" {
-		return fmt.Errorf("filePath is empty")
+// collectCheckpoints advances the committed line as workers finish jobs and
+// persists it, so an interrupted import can resume where it stopped.
+func (m *Manager) collectCheckpoints(checkpoint CheckpointModel) {
+	ctx := context.Background()
+	tracker := newLineTracker(checkpoint.LastCommittedLine)
+	saved := tracker.committed
+	for line := range m.lineCollector {
+		m.counters.processed.Add(1)
+		tracker.done(line)
+		if tracker.committed-saved >= checkpointEvery {
+			checkpoint.LastCommittedLine = tracker.committed
+			m.saveCheckpoint(ctx, checkpoint)
+			saved = tracker.committed
+		}
 	}
-	csvFile, err := os.Open(filePath)
+	checkpoint.LastCommittedLine = tracker.committed
+	checkpoint.Completed = m.lastLine > 0 && tracker.committed >= m.lastLine
+	m.saveCheckpoint(ctx, checkpoint)
+}
+
+func (m *Manager) saveCheckpoint(ctx context.Context, checkpoint CheckpointModel) {
+	err := m.repo.SaveCheckpoint(ctx, checkpoint)
+	if err != nil {
+		m.logger.Error("cannot save checkpoint",
+			zap.Error(err),
+			zap.String("filePath", checkpoint.FilePath),
+			zap.Int("lastCommittedLine", checkpoint.LastCommittedLine),
+		)
+	}
+}
+
+// startDispatcher reads the file and sends every line after skipUntil to the
+// workers.
+func (m *Manager) startDispatcher(ctx context.Context, filePath string, skipUntil int) error {
+	// close chan to signal workers that no more job are incoming.
+	defer close(m.jobCollector)
+	reader, err := openRecordReader(filePath, m.delimiter)
 	if err != nil {
 		return err
 	}
-	defer csvFile.Close()
+	defer reader.Close()
 
-	reader := csv.NewReader(csvFile)
 	errChan := make(chan error)
 	go func(chan error) {
+		var mapping *columnMapping
+		var record []string
+		var err error
 		i := 0
 		for {
-			record, err := reader.Read()
+			record, err = reader.Read()
 			if err == io.EOF {
 				err = nil
+				m.lastLine = i
 				break
+			}
+			if errors.Is(err, ErrMalformedRow) && mapping != nil {
+				i++
+				if i <= skipUntil {
+					m.counters.skipped.Add(1)
+					continue
+				}
+				m.counters.read.Add(1)
+				m.reject(i, record, err)
+				continue
 			} else if err != nil {
 				break
 			}
 			i++
 			if i == 1 {
+				mapping, err = resolveMapping(m.profiles, m.profile, record)
+				if err != nil {
+					break
+				}
+				m.source.Header = record
+				m.source.Profile = mapping.profile
+				continue
+			}
+			if i <= skipUntil {
+				m.counters.skipped.Add(1)
+				continue
+			}
+			m.counters.read.Add(1)
+			job, rowErr := mapping.job(i, record)
+			if rowErr != nil {
+				m.reject(i, record, rowErr)
 				continue
 			}
-			m.jobCollector <- &Job{
-				LineNumber:      i,
-				SeriesReference: record[0],
-				Period:          record[1],
-				DataValue:       record[2],
-				Suppressed:      record[3],
-				Status:          record[4],
-				Units:           record[5],
-				Magnitude:       record[6],
-				Subject:         record[7],
-				Group:           record[8],
-				SeriesTitle1:    record[9],
-				SeriesTitle2:    record[10],
-				SeriesTitle3:    record[11],
-				SeriesTitle4:    record[12],
-				SeriesTitle5:    record[13],
+			//stop reading once the import is cancelled
+			select {
+			case m.jobCollector <- job:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			if err != nil {
+				break
 			}
 		}
-		close(m.jobCollector) // close chan to signal workers that no more job are incoming.
 		errChan <- err
 	}(errChan)
 	err = <-errChan
 	return err
 }
 
-func NewManager(financialService *financial.Service, logger *zap.Logger) *Manager {
+// reject reports a row that never reaches the workers as failed and done.
+func (m *Manager) reject(lineNumber int, record []string, err error) {
+	m.errCollector <- workerErr{
+		Err:        err,
+		LineNumber: lineNumber,
+		Record:     record,
+	}
+	m.lineCollector <- lineNumber
+}
+
+// SetProfile selects the column mapping profile by name. By default the
+// profile is taken from queue.profile or detected from the header.
+func (m *Manager) SetProfile(name string) error {
+	if name == "" {
+		m.profile = ""
+		return nil
+	}
+	_, ok := m.profiles[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown mapping profile %s", name)
+	}
+	m.profile = name
+	return nil
+}
+
+func NewManager(cfg *config.Cfg, financialService *financial.Service, repo *Repository, logger *zap.Logger) *Manager {
 	collector := make(chan *Job, 1000)
 	errChan := make(chan workerErr, 1000)
+	lineChan := make(chan int, 1000)
+	batchSize := cfg.GetInt("queue.batchSize")
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	flushInterval := cfg.GetDuration("queue.flushInterval")
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	var delimiter rune
+	if d := cfg.GetString("queue.delimiter"); d != "" {
+		delimiter, _ = utf8.DecodeRuneInString(d)
+	}
 	return &Manager{
 		jobCollector:     collector,
 		errCollector:     errChan,
+		lineCollector:    lineChan,
 		financialService: financialService,
+		repo:             repo,
 		logger:           logger,
-		quit:             make(chan bool),
+		batchSize:        batchSize,
+		flushInterval:    flushInterval,
+		profiles:         loadProfiles(cfg),
+		profile:          cfg.GetString("queue.profile"),
+		delimiter:        delimiter,
 	}
 }
@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"we-connect-test/config"
+)
+
+const defaultProfileName = "statsnz"
+
+var ErrMalformedRow = errors.New("malformed row")
+
+// Profile maps job fields to the csv header names that hold them. Field names
+// are matched case insensitively because viper lowercases config keys.
+type Profile struct {
+	Name    string
+	Columns map[string]string
+}
+
+// requiredFields must be present in the header for a profile to be used.
+var requiredFields = []string{"seriesreference", "period", "datavalue", "magnitude"}
+
+// nonEmptyFields must have a value in every row. dataValue is required in the
+// header but is empty for suppressed observations.
+var nonEmptyFields = []string{"seriesreference", "period", "magnitude"}
+
+var jobFieldSetters = map[string]func(j *Job, v string){
+	"seriesreference": func(j *Job, v string) { j.SeriesReference = v },
+	"period":          func(j *Job, v string) { j.Period = v },
+	"datavalue":       func(j *Job, v string) { j.DataValue = v },
+	"suppressed":      func(j *Job, v string) { j.Suppressed = v },
+	"status":          func(j *Job, v string) { j.Status = v },
+	"units":           func(j *Job, v string) { j.Units = v },
+	"magnitude":       func(j *Job, v string) { j.Magnitude = v },
+	"subject":         func(j *Job, v string) { j.Subject = v },
+	"group":           func(j *Job, v string) { j.Group = v },
+	"seriestitle1":    func(j *Job, v string) { j.SeriesTitle1 = v },
+	"seriestitle2":    func(j *Job, v string) { j.SeriesTitle2 = v },
+	"seriestitle3":    func(j *Job, v string) { j.SeriesTitle3 = v },
+	"seriestitle4":    func(j *Job, v string) { j.SeriesTitle4 = v },
+	"seriestitle5":    func(j *Job, v string) { j.SeriesTitle5 = v },
+}
+
+var defaultProfile = Profile{
+	Name: defaultProfileName,
+	Columns: map[string]string{
+		"seriesreference": "Series_reference",
+		"period":          "Period",
+		"datavalue":       "Data_value",
+		"suppressed":      "Suppressed",
+		"status":          "STATUS",
+		"units":           "UNITS",
+		"magnitude":       "Magnitude",
+		"subject":         "Subject",
+		"group":           "Group",
+		"seriestitle1":    "Series_title_1",
+		"seriestitle2":    "Series_title_2",
+		"seriestitle3":    "Series_title_3",
+		"seriestitle4":    "Series_title_4",
+		"seriestitle5":    "Series_title_5",
+	},
+}
+
+// columnMapping is a profile resolved against the header of one file.
+type columnMapping struct {
+	profile string
+	columns int
+	indexes map[string]int
+}
+
+// job builds the job of one record, rejecting records that do not match the
+// header instead of reading out of range.
+func (c *columnMapping) job(lineNumber int, record []string) (*Job, error) {
+	if len(record) != c.columns {
+		return nil, fmt.Errorf("%w: expected %d columns, got %d", ErrMalformedRow, c.columns, len(record))
+	}
+	job := &Job{LineNumber: lineNumber, Record: record}
+	for field, index := range c.indexes {
+		jobFieldSetters[field](job, strings.TrimSpace(record[index]))
+	}
+	for _, field := range nonEmptyFields {
+		if strings.TrimSpace(record[c.indexes[field]]) == "" {
+			return nil, fmt.Errorf("%w: %s is empty", ErrMalformedRow, field)
+		}
+	}
+	return job, nil
+}
+
+func newColumnMapping(profile Profile, header []string) (*columnMapping, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		//files saved by excel start with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		positions[strings.ToLower(name)] = i
+	}
+	indexes := make(map[string]int, len(profile.Columns))
+	for field, column := range profile.Columns {
+		field = strings.ToLower(field)
+		if _, ok := jobFieldSetters[field]; !ok {
+			return nil, fmt.Errorf("profile %s has unknown field %s", profile.Name, field)
+		}
+		index, ok := positions[strings.ToLower(strings.TrimSpace(column))]
+		if !ok {
+			//json lines files usually name their keys after the fields
+			index, ok = positions[field]
+		}
+		if ok {
+			indexes[field] = index
+		}
+	}
+	for _, field := range requiredFields {
+		if _, ok := indexes[field]; !ok {
+			return nil, fmt.Errorf("profile %s: header has no column for %s", profile.Name, field)
+		}
+	}
+	return &columnMapping{
+		profile: profile.Name,
+		columns: len(header),
+		indexes: indexes,
+	}, nil
+}
+
+// resolveMapping uses the named profile, or when name is empty the first
+// profile whose required fields are all found in the header, trying the
+// built in profile first and the others by name.
+func resolveMapping(profiles map[string]Profile, name string, header []string) (*columnMapping, error) {
+	if name != "" {
+		profile, ok := profiles[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown mapping profile %s", name)
+		}
+		return newColumnMapping(profile, header)
+	}
+	names := make([]string, 0, len(profiles))
+	for n := range profiles {
+		names = append(names, n)
+	}
+	//the built in profile is tried first, the rest in a stable order
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == defaultProfileName || names[j] == defaultProfileName {
+			return names[i] == defaultProfileName
+		}
+		return names[i] < names[j]
+	})
+	for _, n := range names {
+		mapping, err := newColumnMapping(profiles[n], header)
+		if err == nil {
+			return mapping, nil
+		}
+	}
+	return nil, fmt.Errorf("no mapping profile matches header %v", header)
+}
+
+// loadProfiles returns the built in profile and those defined under
+// queue.profiles in the config.
+func loadProfiles(cfg *config.Cfg) map[string]Profile {
+	profiles := map[string]Profile{defaultProfileName: defaultProfile}
+	for name := range cfg.GetStringMap("queue.profiles") {
+		profiles[name] = Profile{
+			Name:    name,
+			Columns: cfg.GetStringMapString("queue.profiles." + name),
+		}
+	}
+	return profiles
+}
@@ -0,0 +1,305 @@
+package queue
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sniffSize is how many bytes are looked at to detect the format of a file
+// whose extension does not tell it.
+const sniffSize = 4096
+
+// maxRecordSize is the longest line accepted in a json lines file.
+const maxRecordSize = 1 << 20
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	bom       = []byte("\ufeff")
+)
+
+// recordReader returns the records of a file one by one, the first one being
+// the header. An error wrapping ErrMalformedRow is about that record only and
+// reading can continue, any other error ends the file.
+type recordReader interface {
+	Read() ([]string, error)
+	Close() error
+}
+
+// openRecordReader opens filePath with the reader of its format. Compression
+// and format are taken from the extension, or detected from the content when
+// the extension is unknown. delimiter overrides the separator of delimited
+// files, 0 means comma for .csv, tab for .tsv and detected otherwise.
+func openRecordReader(filePath string, delimiter rune) (recordReader, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	name := filePath
+	br := bufio.NewReaderSize(f, sniffSize)
+	head, _ := br.Peek(sniffSize)
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case ext == ".zip" || bytes.HasPrefix(head, zipMagic):
+		//zip needs random access, so the archive is reopened by path
+		f.Close()
+		return openZipRecordReader(filePath, delimiter)
+	case ext == ".gz" || bytes.HasPrefix(head, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ext == ".gz" {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		return newFormatReader(name, gz, delimiter, closers{f, gz}), nil
+	}
+	return newFormatReader(name, br, delimiter, f), nil
+}
+
+// openZipRecordReader reads the only data file of a zip archive.
+func openZipRecordReader(filePath string, delimiter rune) (recordReader, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*zip.File
+	for _, entry := range archive.File {
+		base := path.Base(entry.Name)
+		//skip folders and the metadata macOS adds to archives
+		if entry.FileInfo().IsDir() || strings.HasPrefix(entry.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 1 {
+		archive.Close()
+		return nil, fmt.Errorf("zip archive must contain exactly one file, found %d", len(entries))
+	}
+	rc, err := entries[0].Open()
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return newFormatReader(entries[0].Name, rc, delimiter, closers{archive, rc}), nil
+}
+
+// newFormatReader picks the json lines or the delimited reader for name, or
+// from the content of r when the extension is unknown.
+func newFormatReader(name string, r io.Reader, delimiter rune, closer io.Closer) recordReader {
+	br := bufio.NewReaderSize(r, sniffSize)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return newJSONLinesReader(br, closer)
+	case ".csv":
+		if delimiter == 0 {
+			delimiter = ','
+		}
+	case ".tsv", ".tab":
+		if delimiter == 0 {
+			delimiter = '\t'
+		}
+	default:
+		head, _ := br.Peek(sniffSize)
+		head = bytes.TrimLeft(bytes.TrimPrefix(head, bom), " \t\r\n")
+		if bytes.HasPrefix(head, []byte("{")) {
+			return newJSONLinesReader(br, closer)
+		}
+		if delimiter == 0 {
+			delimiter = sniffDelimiter(head)
+		}
+	}
+	return newDelimitedReader(br, delimiter, closer)
+}
+
+// sniffDelimiter returns the candidate found most often outside quotes in the
+// first line, comma when none is found.
+func sniffDelimiter(head []byte) rune {
+	candidates := []rune{',', '\t', ';', '|'}
+	counts := make(map[rune]int, len(candidates))
+	quoted := false
+	for _, r := range string(head) {
+		if r == '"' {
+			quoted = !quoted
+			continue
+		}
+		if r == '\n' && !quoted {
+			break
+		}
+		if !quoted {
+			counts[r]++
+		}
+	}
+	delimiter := ','
+	for _, c := range candidates {
+		if counts[c] > counts[delimiter] {
+			delimiter = c
+		}
+	}
+	return delimiter
+}
+
+type delimitedReader struct {
+	reader *csv.Reader
+	io.Closer
+}
+
+func (r *delimitedReader) Read() ([]string, error) {
+	record, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return record, fmt.Errorf("%w: %s", ErrMalformedRow, err)
+	}
+	return record, err
+}
+
+func newDelimitedReader(r io.Reader, delimiter rune, closer io.Closer) *delimitedReader {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	//rows are checked against the header by the column mapping
+	reader.FieldsPerRecord = -1
+	return &delimitedReader{reader: reader, Closer: closer}
+}
+
+// jsonLinesReader reads one json object per line. The keys of the first
+// object are the header and every record holds the values in header order,
+// keys missing from an object are empty and keys not in the header ignored.
+type jsonLinesReader struct {
+	scanner *bufio.Scanner
+	header  []string
+	// first is the record of the first object, returned after the header.
+	first []string
+	io.Closer
+}
+
+func (r *jsonLinesReader) Read() ([]string, error) {
+	if r.first != nil {
+		record := r.first
+		r.first = nil
+		return record, nil
+	}
+	line, err := r.nextLine()
+	if err != nil {
+		return nil, err
+	}
+	keys, values, err := decodeObject(line)
+	if r.header == nil {
+		if err != nil {
+			return nil, err
+		}
+		r.header = keys
+		r.first = r.record(values)
+		return r.header, nil
+	}
+	if err != nil {
+		return []string{string(line)}, fmt.Errorf("%w: %s", ErrMalformedRow, err)
+	}
+	return r.record(values), nil
+}
+
+// nextLine skips blank lines.
+func (r *jsonLinesReader) nextLine() ([]byte, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(bytes.TrimPrefix(r.scanner.Bytes(), bom))
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *jsonLinesReader) record(values map[string]string) []string {
+	record := make([]string, len(r.header))
+	for i, key := range r.header {
+		record[i] = values[key]
+	}
+	return record
+}
+
+func newJSONLinesReader(r io.Reader, closer io.Closer) *jsonLinesReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, sniffSize), maxRecordSize)
+	return &jsonLinesReader{scanner: scanner, Closer: closer}
+}
+
+// decodeObject returns the keys of a json object in the order they appear and
+// its values as text. Strings are unquoted, null is empty and numbers keep
+// their exact text.
+func decodeObject(line []byte) (keys []string, values map[string]string, err error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	token, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, nil, fmt.Errorf("expected a json object")
+	}
+	values = make(map[string]string)
+	for dec.More() {
+		token, err = dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := token.(string)
+		var raw json.RawMessage
+		err = dec.Decode(&raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key], err = rawText(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	_, err = dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if dec.More() {
+		return nil, nil, fmt.Errorf("unexpected data after json object")
+	}
+	return keys, values, nil
+}
+
+func rawText(raw json.RawMessage) (string, error) {
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return "", nil
+	case bytes.HasPrefix(raw, []byte(`"`)):
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	return string(raw), nil
+}
+
+// closers closes every closer, the last one first.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var err error
+	for i := len(c) - 1; i >= 0; i-- {
+		if closeErr := c[i].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
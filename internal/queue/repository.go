@@ -0,0 +1,263 @@
+package queue
+
+import (
+	"context"
+	"time"
+	"we-connect-test/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	checkpointCollectionName = "importCheckpoints"
+	deadLetterCollectionName = "importDeadLetters"
+	importJobCollectionName  = "importJobs"
+)
+
+const (
+	ImportStatusPending     = "pending"
+	ImportStatusRunning     = "running"
+	ImportStatusCompleted   = "completed"
+	ImportStatusFailed      = "failed"
+	ImportStatusCancelled   = "cancelled"
+	ImportStatusInterrupted = "interrupted"
+)
+
+// CheckpointModel records how far the import of a file got. Files are
+// identified by the fingerprint of their content so a renamed or re-copied
+// file is still recognised.
+type CheckpointModel struct {
+	Fingerprint       string    `bson:"_id"`
+	FilePath          string    `bson:"filePath"`
+	LastCommittedLine int       `bson:"lastCommittedLine"`
+	Completed         bool      `bson:"completed"`
+	UpdatedAt         time.Time `bson:"updatedAt"`
+}
+
+// DeadLetterModel is a row that could not be imported. It keeps the raw
+// record and the header it was read with so the row can be fixed and retried.
+type DeadLetterModel struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	SourceFile  string             `bson:"sourceFile"`
+	Fingerprint string             `bson:"fingerprint"`
+	LineNumber  int                `bson:"lineNumber"`
+	Profile     string             `bson:"profile"`
+	Header      []string           `bson:"header"`
+	Record      []string           `bson:"record"`
+	Error       string             `bson:"error"`
+	Attempts    int                `bson:"attempts"`
+	CreatedAt   time.Time          `bson:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt"`
+}
+
+// ImportJobModel is a file uploaded over http and imported in the background.
+type ImportJobModel struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	FileName   string             `bson:"fileName"`
+	FilePath   string             `bson:"filePath"`
+	Profile    string             `bson:"profile"`
+	Status     string             `bson:"status"`
+	RowsRead   int64              `bson:"rowsRead"`
+	Inserted   int64              `bson:"inserted"`
+	Failed     int64              `bson:"failed"`
+	Skipped    int64              `bson:"skipped"`
+	Error      string             `bson:"error"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	StartedAt  *time.Time         `bson:"startedAt"`
+	FinishedAt *time.Time         `bson:"finishedAt"`
+}
+
+type Repository struct {
+	dbName        string
+	mongoDBClient *mongo.Client
+}
+
+func (r *Repository) GetCheckpoint(ctx context.Context, fingerprint string) (CheckpointModel, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(checkpointCollectionName)
+	res := coll.FindOne(ctx, bson.M{"_id": fingerprint})
+	if res.Err() != nil {
+		return CheckpointModel{}, res.Err()
+	}
+	m := CheckpointModel{}
+	err := res.Decode(&m)
+	return m, err
+}
+
+func (r *Repository) SaveCheckpoint(ctx context.Context, m CheckpointModel) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(checkpointCollectionName)
+	m.UpdatedAt = time.Now().UTC()
+	opts := options.Replace().SetUpsert(true)
+	_, err := coll.ReplaceOne(ctx, bson.M{"_id": m.Fingerprint}, m, opts)
+	return err
+}
+
+// SaveDeadLetter stores a failed row. A row of the same file and line that
+// failed before is updated and its attempt count is increased.
+func (r *Repository) SaveDeadLetter(ctx context.Context, m DeadLetterModel) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(deadLetterCollectionName)
+	now := time.Now().UTC()
+	filter := bson.M{"fingerprint": m.Fingerprint, "lineNumber": m.LineNumber}
+	update := bson.M{
+		"$set": bson.M{
+			"sourceFile": m.SourceFile,
+			"profile":    m.Profile,
+			"header":     m.Header,
+			"record":     m.Record,
+			"error":      m.Error,
+			"updatedAt":  now,
+		},
+		"$inc":         bson.M{"attempts": 1},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "createdAt": now},
+	}
+	_, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *Repository) GetDeadLettersByPagination(ctx context.Context, sourceFile string, page, pageSize int) ([]DeadLetterModel, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(pageSize)).
+		SetSkip(int64(page * pageSize))
+	filter := bson.M{}
+	if sourceFile != "" {
+		filter["sourceFile"] = sourceFile
+	}
+	coll := r.mongoDBClient.Database(r.dbName).Collection(deadLetterCollectionName)
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var results []DeadLetterModel
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *Repository) GetDeadLetterByID(ctx context.Context, id string) (DeadLetterModel, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(deadLetterCollectionName)
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return DeadLetterModel{}, err
+	}
+	res := coll.FindOne(ctx, bson.M{"_id": objectID})
+	if res.Err() != nil {
+		return DeadLetterModel{}, res.Err()
+	}
+	m := DeadLetterModel{}
+	err = res.Decode(&m)
+	return m, err
+}
+
+func (r *Repository) UpdateDeadLetterRecord(ctx context.Context, id string, record []string) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(deadLetterCollectionName)
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"record": record, "updatedAt": time.Now().UTC()}}
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	return err
+}
+
+// RecordDeadLetterFailure stores the error of a failed retry.
+func (r *Repository) RecordDeadLetterFailure(ctx context.Context, id string, errMessage string) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(deadLetterCollectionName)
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$set": bson.M{"error": errMessage, "updatedAt": time.Now().UTC()},
+		"$inc": bson.M{"attempts": 1},
+	}
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	return err
+}
+
+func (r *Repository) DeleteDeadLetter(ctx context.Context, id string) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(deadLetterCollectionName)
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = coll.DeleteOne(ctx, bson.M{"_id": objectID})
+	return err
+}
+
+func (r *Repository) CreateImportJob(ctx context.Context, m ImportJobModel) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(importJobCollectionName)
+	m.CreatedAt = time.Now().UTC()
+	_, err := coll.InsertOne(ctx, m)
+	return err
+}
+
+func (r *Repository) GetImportJobByID(ctx context.Context, id string) (ImportJobModel, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(importJobCollectionName)
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ImportJobModel{}, err
+	}
+	res := coll.FindOne(ctx, bson.M{"_id": objectID})
+	if res.Err() != nil {
+		return ImportJobModel{}, res.Err()
+	}
+	m := ImportJobModel{}
+	err = res.Decode(&m)
+	return m, err
+}
+
+// UpdateImportJob sets status, counters and timestamps of an import job.
+func (r *Repository) UpdateImportJob(ctx context.Context, m ImportJobModel) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(importJobCollectionName)
+	update := bson.M{"$set": bson.M{
+		"status":     m.Status,
+		"rowsRead":   m.RowsRead,
+		"inserted":   m.Inserted,
+		"failed":     m.Failed,
+		"skipped":    m.Skipped,
+		"error":      m.Error,
+		"startedAt":  m.StartedAt,
+		"finishedAt": m.FinishedAt,
+	}}
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": m.ID}, update)
+	return err
+}
+
+// MarkInterruptedImportJobs flags jobs that were still pending or running
+// when the process stopped, since nothing will finish them anymore.
+func (r *Repository) MarkInterruptedImportJobs(ctx context.Context) (int64, error) {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(importJobCollectionName)
+	filter := bson.M{"status": bson.M{"$in": bson.A{ImportStatusPending, ImportStatusRunning}}}
+	update := bson.M{"$set": bson.M{"status": ImportStatusInterrupted}}
+	res, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+func (r *Repository) EnsureIndexes(ctx context.Context) error {
+	coll := r.mongoDBClient.Database(r.dbName).Collection(deadLetterCollectionName)
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "fingerprint", Value: 1}, {Key: "lineNumber", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "sourceFile", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	})
+	return err
+}
+
+func NewRepository(cfg *config.Cfg, mongoDBClient *mongo.Client) *Repository {
+	return &Repository{
+		dbName:        cfg.GetString("mongodb.dbname"),
+		mongoDBClient: mongoDBClient,
+	}
+}
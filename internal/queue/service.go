@@ -0,0 +1,256 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"we-connect-test/config"
+	"we-connect-test/internal/financial"
+	"we-connect-test/internal/response"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type Service struct {
+	cfg              *config.Cfg
+	repo             *Repository
+	financialService *financial.Service
+	profiles         map[string]Profile
+	logger           *zap.Logger
+	uploadDir        string
+	workerCount      int
+	// running holds the cancel func of every import started by this process.
+	running   map[string]context.CancelFunc
+	runningMu sync.Mutex
+}
+
+type GetDeadLetterListParams struct {
+	Page       int    `form:"page"`
+	PageSize   int    `form:"pageSize"`
+	SourceFile string `form:"sourceFile"`
+}
+
+type GetDeadLetterParams struct {
+	ID string `json:"-"`
+}
+
+type FixDeadLetterParams struct {
+	ID     string   `json:"-"`
+	Record []string `json:"record"`
+}
+
+type RetryDeadLetterParams struct {
+	ID string `json:"-"`
+}
+
+type SingleDeadLetterResult struct {
+	ID         string    `json:"id"`
+	SourceFile string    `json:"sourceFile"`
+	LineNumber int       `json:"lineNumber"`
+	Profile    string    `json:"profile"`
+	Header     []string  `json:"header"`
+	Record     []string  `json:"record"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func (s *Service) GetDeadLetterList(
+	ctx context.Context,
+	params GetDeadLetterListParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	if params.Page < 0 {
+		params.Page = 0
+	}
+	if params.PageSize < 2 {
+		params.PageSize = 2
+	}
+	if params.PageSize > 100 {
+		params.PageSize = 100
+	}
+	models, err := s.repo.GetDeadLettersByPagination(ctx, params.SourceFile, params.Page, params.PageSize)
+	if err != nil {
+		s.logger.Error("cannot GetDeadLettersByPagination",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "GetDeadLetterList"),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make([]SingleDeadLetterResult, len(models))
+	for i, m := range models {
+		res[i] = toSingleDeadLetterResult(m)
+	}
+	return response.Success(res, "")
+}
+
+func (s *Service) GetDeadLetter(
+	ctx context.Context,
+	params GetDeadLetterParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	m, resp, statusCode := s.getDeadLetter(ctx, params.ID, "GetDeadLetter")
+	if statusCode != http.StatusOK {
+		return resp, statusCode
+	}
+	return response.Success(toSingleDeadLetterResult(m), "")
+}
+
+// FixDeadLetter replaces the raw record of a failed row. The row is imported
+// again only when RetryDeadLetter is called.
+func (s *Service) FixDeadLetter(
+	ctx context.Context,
+	params FixDeadLetterParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	m, resp, statusCode := s.getDeadLetter(ctx, params.ID, "FixDeadLetter")
+	if statusCode != http.StatusOK {
+		return resp, statusCode
+	}
+	if len(params.Record) != len(m.Header) {
+		message := fmt.Sprintf("record must have %d columns to match the header", len(m.Header))
+		return response.Error(message, http.StatusBadRequest, nil)
+	}
+	err := s.repo.UpdateDeadLetterRecord(ctx, params.ID, params.Record)
+	if err != nil {
+		s.logger.Error("cannot UpdateDeadLetterRecord",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "FixDeadLetter"),
+			zap.String("id", params.ID),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	m.Record = params.Record
+	return response.Success(toSingleDeadLetterResult(m), "")
+}
+
+// RetryDeadLetter imports a failed row again. The dead letter is removed once
+// the row is stored, otherwise the new error is recorded as another attempt.
+func (s *Service) RetryDeadLetter(
+	ctx context.Context,
+	params RetryDeadLetterParams,
+) (apiResponse response.ApiResponse, statusCode int) {
+	m, resp, statusCode := s.getDeadLetter(ctx, params.ID, "RetryDeadLetter")
+	if statusCode != http.StatusOK {
+		return resp, statusCode
+	}
+	model, err := s.deadLetterModel(m)
+	if err == nil {
+		err = s.financialService.UpsertFinancialData(ctx, model)
+	}
+	if err != nil {
+		recordErr := s.repo.RecordDeadLetterFailure(ctx, params.ID, err.Error())
+		if recordErr != nil {
+			s.logger.Error("cannot RecordDeadLetterFailure",
+				zap.Error(recordErr),
+				zap.String("service", "queueService"),
+				zap.String("method", "RetryDeadLetter"),
+				zap.String("id", params.ID),
+			)
+		}
+		return response.Error(err.Error(), http.StatusUnprocessableEntity, nil)
+	}
+	err = s.repo.DeleteDeadLetter(ctx, params.ID)
+	if err != nil {
+		s.logger.Error("cannot DeleteDeadLetter",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", "RetryDeadLetter"),
+			zap.String("id", params.ID),
+		)
+		return response.Error("something went wrong", http.StatusInternalServerError, nil)
+	}
+	res := make(map[string]string)
+	return response.Success(res, "")
+}
+
+func (s *Service) EnsureIndexes(ctx context.Context) error {
+	return s.repo.EnsureIndexes(ctx)
+}
+
+func (s *Service) getDeadLetter(
+	ctx context.Context,
+	id string,
+	method string,
+) (m DeadLetterModel, apiResponse response.ApiResponse, statusCode int) {
+	if !primitive.IsValidObjectID(id) {
+		apiResponse, statusCode = response.Error("invalid id", http.StatusBadRequest, nil)
+		return m, apiResponse, statusCode
+	}
+	m, err := s.repo.GetDeadLetterByID(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		apiResponse, statusCode = response.Error("not found", http.StatusNotFound, nil)
+		return m, apiResponse, statusCode
+	}
+	if err != nil {
+		s.logger.Error("cannot GetDeadLetterByID",
+			zap.Error(err),
+			zap.String("service", "queueService"),
+			zap.String("method", method),
+			zap.String("id", id),
+		)
+		apiResponse, statusCode = response.Error("something went wrong", http.StatusInternalServerError, nil)
+		return m, apiResponse, statusCode
+	}
+	return m, response.ApiResponse{}, http.StatusOK
+}
+
+// deadLetterModel maps the raw record of a dead letter with the profile it was
+// originally read with.
+func (s *Service) deadLetterModel(m DeadLetterModel) (financial.FinancialModel, error) {
+	mapping, err := resolveMapping(s.profiles, m.Profile, m.Header)
+	if err != nil {
+		return financial.FinancialModel{}, err
+	}
+	job, err := mapping.job(m.LineNumber, m.Record)
+	if err != nil {
+		return financial.FinancialModel{}, err
+	}
+	return job.toFinancialModel()
+}
+
+func toSingleDeadLetterResult(m DeadLetterModel) SingleDeadLetterResult {
+	return SingleDeadLetterResult{
+		ID:         m.ID.Hex(),
+		SourceFile: m.SourceFile,
+		LineNumber: m.LineNumber,
+		Profile:    m.Profile,
+		Header:     m.Header,
+		Record:     m.Record,
+		Error:      m.Error,
+		Attempts:   m.Attempts,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+	}
+}
+
+func NewService(
+	cfg *config.Cfg,
+	repo *Repository,
+	financialService *financial.Service,
+	logger *zap.Logger,
+) *Service {
+	uploadDir := cfg.GetString("queue.uploadDir")
+	if uploadDir == "" {
+		uploadDir = defaultUploadDir
+	}
+	workerCount := cfg.GetInt("queue.workerCount")
+	if workerCount <= 0 {
+		workerCount = defaultWorkerCount
+	}
+	return &Service{
+		cfg:              cfg,
+		repo:             repo,
+		financialService: financialService,
+		profiles:         loadProfiles(cfg),
+		logger:           logger,
+		uploadDir:        uploadDir,
+		workerCount:      workerCount,
+		running:          make(map[string]context.CancelFunc),
+	}
+}
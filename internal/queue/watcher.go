@@ -0,0 +1,215 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"we-connect-test/config"
+	"we-connect-test/internal/financial"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWatchInterval = 10 * time.Second
+	archiveDirName       = "archive"
+	rejectedDirName      = "rejected"
+	reportSuffix         = ".error.json"
+)
+
+// Watcher imports every file copied into a drop folder. Imported files are
+// moved to archive/, files that fail or have failed rows are moved to
+// rejected/ next to a report of what went wrong.
+type Watcher struct {
+	cfg              *config.Cfg
+	financialService *financial.Service
+	repo             *Repository
+	logger           *zap.Logger
+	dir              string
+	interval         time.Duration
+	workerCount      int
+	// seen holds the size and modification time of the files found by the
+	// previous scan. A file is imported once they stop changing, so a file
+	// that is still being copied is left alone.
+	seen map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Report is written next to a rejected file.
+type Report struct {
+	File        string    `json:"file"`
+	Error       string    `json:"error,omitempty"`
+	Inserted    int64     `json:"inserted"`
+	FailedLines []int     `json:"failedLines,omitempty"`
+	FinishedAt  time.Time `json:"finishedAt"`
+}
+
+// Start scans the folder every interval until ctx is done. An import that is
+// cancelled leaves its file in place, it resumes from its checkpoint on the
+// next start.
+func (w *Watcher) Start(ctx context.Context) error {
+	for _, dir := range []string{w.dir, w.archiveDir(), w.rejectedDir()} {
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+	w.logger.Info("watching drop folder", zap.String("dir", w.dir))
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.scan(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) scan(ctx context.Context) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		w.logger.Error("cannot read drop folder", zap.Error(err), zap.String("dir", w.dir))
+		return
+	}
+	seen := make(map[string]fileState, len(entries))
+	var ready []string
+	for _, entry := range entries {
+		//hidden files are used by copies that are renamed once complete
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		seen[entry.Name()] = state
+		if previous, ok := w.seen[entry.Name()]; ok && previous == state {
+			ready = append(ready, entry.Name())
+		}
+	}
+	w.seen = seen
+	sort.Strings(ready)
+	for _, name := range ready {
+		if ctx.Err() != nil {
+			return
+		}
+		w.importFile(ctx, name)
+		delete(w.seen, name)
+	}
+}
+
+func (w *Watcher) importFile(ctx context.Context, name string) {
+	filePath := filepath.Join(w.dir, name)
+	manager := NewManager(w.cfg, w.financialService, w.repo, w.logger)
+	summary, err := manager.Run(ctx, filePath, w.workerCount)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil && len(summary.FailedLines) == 0 {
+		archived, moveErr := moveFile(filePath, w.archiveDir())
+		if moveErr != nil {
+			w.logger.Error("cannot archive imported file", zap.Error(moveErr), zap.String("filePath", filePath))
+			return
+		}
+		w.logger.Info("imported dropped file",
+			zap.String("filePath", archived),
+			zap.Int64("inserted", summary.Inserted),
+			zap.Duration("duration", summary.Duration),
+		)
+		return
+	}
+	report := Report{
+		File:        name,
+		Inserted:    summary.Inserted,
+		FailedLines: summary.FailedLines,
+		FinishedAt:  time.Now().UTC(),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Error = fmt.Sprintf("%d rows failed, they are stored as dead letters", len(summary.FailedLines))
+	}
+	rejected, moveErr := moveFile(filePath, w.rejectedDir())
+	if moveErr != nil {
+		w.logger.Error("cannot reject dropped file", zap.Error(moveErr), zap.String("filePath", filePath))
+		return
+	}
+	moveErr = writeReport(rejected+reportSuffix, report)
+	if moveErr != nil {
+		w.logger.Error("cannot write rejection report", zap.Error(moveErr), zap.String("filePath", rejected))
+	}
+	w.logger.Warn("rejected dropped file",
+		zap.String("filePath", rejected),
+		zap.String("reason", report.Error),
+	)
+}
+
+func (w *Watcher) archiveDir() string {
+	return filepath.Join(w.dir, archiveDirName)
+}
+
+func (w *Watcher) rejectedDir() string {
+	return filepath.Join(w.dir, rejectedDirName)
+}
+
+// moveFile moves filePath into dir. A file of the same name already there is
+// kept, the moved one gets a timestamp prefix instead.
+func moveFile(filePath string, dir string) (string, error) {
+	target := filepath.Join(dir, filepath.Base(filePath))
+	_, err := os.Stat(target)
+	if err == nil {
+		prefix := time.Now().UTC().Format("20060102T150405.000000000")
+		target = filepath.Join(dir, prefix+"_"+filepath.Base(filePath))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return target, os.Rename(filePath, target)
+}
+
+func writeReport(path string, report Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// NewWatcher returns the watcher of queue.watch.dir, or nil when no folder is
+// configured.
+func NewWatcher(cfg *config.Cfg, financialService *financial.Service, repo *Repository, logger *zap.Logger) *Watcher {
+	dir := cfg.GetString("queue.watch.dir")
+	if dir == "" {
+		return nil
+	}
+	interval := cfg.GetDuration("queue.watch.interval")
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	workerCount := cfg.GetInt("queue.workerCount")
+	if workerCount <= 0 {
+		workerCount = defaultWorkerCount
+	}
+	return &Watcher{
+		cfg:              cfg,
+		financialService: financialService,
+		repo:             repo,
+		logger:           logger,
+		dir:              dir,
+		interval:         interval,
+		workerCount:      workerCount,
+		seen:             make(map[string]fileState),
+	}
+}
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"context"
+	"time"
 	"we-connect-test/internal/financial"
 )
 
@@ -9,41 +10,105 @@ type worker struct {
 	ID               int
 	jobChan          chan *Job
 	errChan          chan workerErr
+	lineChan         chan int
 	financialService *financial.Service
+	batchSize        int
+	flushInterval    time.Duration
 }
 
+// start collects jobs into batches and writes a batch once it is full, once
+// flushInterval passes or once jobChan is closed. When ctx is cancelled the
+// pending batch is dropped, its lines are not committed so a later run
+// imports them again.
 func (w *worker) start(ctx context.Context) {
-	for job := range w.jobChan {
-		_, err := w.financialService.CreateFinancialData(ctx, financial.FinancialModel{
-			SeriesReference: job.SeriesReference,
-			Period:          job.Period,
-			DataValue:       job.DataValue,
-			Suppressed:      job.Suppressed,
-			Status:          job.Status,
-			Units:           job.Units,
-			Magnitude:       job.Magnitude,
-			Subject:         job.Subject,
-			Group:           job.Group,
-			SeriesTitle1:    job.SeriesTitle1,
-			SeriesTitle2:    job.SeriesTitle2,
-			SeriesTitle3:    job.SeriesTitle3,
-			SeriesTitle4:    job.SeriesTitle4,
-			SeriesTitle5:    job.SeriesTitle5,
-		})
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Job, 0, w.batchSize)
+	for {
+		select {
+		case job, ok := <-w.jobChan:
+			if !ok {
+				w.flush(ctx, batch)
+				return
+			}
+			batch = append(batch, job)
+			if len(batch) >= w.batchSize {
+				w.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(ctx, batch)
+			batch = batch[:0]
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *worker) flush(ctx context.Context, batch []*Job) {
+	if len(batch) == 0 {
+		return
+	}
+	models := make([]financial.FinancialModel, 0, len(batch))
+	//stored holds the jobs whose model is in models, at the same index
+	stored := make([]*Job, 0, len(batch))
+	var rejected []*Job
+	for _, job := range batch {
+		m, err := job.toFinancialModel()
 		if err != nil {
-			w.errChan <- workerErr{
-				Err:        err,
-				LineNumber: job.LineNumber,
+			w.fail(job, err)
+			rejected = append(rejected, job)
+			continue
+		}
+		models = append(models, m)
+		stored = append(stored, job)
+	}
+	if len(models) > 0 {
+		failed, err := w.financialService.UpsertManyFinancialData(ctx, models)
+		if err != nil && ctx.Err() != nil {
+			//the write was aborted by the cancellation, the rows did not fail
+			for _, job := range rejected {
+				w.lineChan <- job.LineNumber
+			}
+			return
+		}
+		for i, job := range stored {
+			if err != nil {
+				w.fail(job, err)
+			} else if failed[i] != nil {
+				w.fail(job, failed[i])
 			}
 		}
 	}
+	for _, job := range batch {
+		w.lineChan <- job.LineNumber
+	}
+}
+
+func (w *worker) fail(job *Job, err error) {
+	w.errChan <- workerErr{
+		Err:        err,
+		LineNumber: job.LineNumber,
+		Record:     job.Record,
+	}
 }
 
-func newWorker(jobChan chan *Job, errChan chan workerErr, workerID int, financialService *financial.Service) *worker {
+func newWorker(
+	jobChan chan *Job,
+	errChan chan workerErr,
+	lineChan chan int,
+	workerID int,
+	financialService *financial.Service,
+	batchSize int,
+	flushInterval time.Duration,
+) *worker {
 	return &worker{
 		ID:               workerID,
 		jobChan:          jobChan,
 		errChan:          errChan,
+		lineChan:         lineChan,
 		financialService: financialService,
+		batchSize:        batchSize,
+		flushInterval:    flushInterval,
 	}
 }
@@ -6,6 +6,7 @@ type ApiResponse struct {
 	Status  bool        `json:"status"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
+	Meta    interface{} `json:"meta,omitempty"`
 }
 
 func Success(data interface{}, message string) (resp ApiResponse, status int) {
@@ -17,6 +18,27 @@ func Success(data interface{}, message string) (resp ApiResponse, status int) {
 	return resp, http.StatusOK
 }
 
+// Created is Success for a request that created a resource.
+func Created(data interface{}, message string) (resp ApiResponse, status int) {
+	resp, _ = Success(data, message)
+	return resp, http.StatusCreated
+}
+
+// NoContent is Success for a request with nothing to return, such as a
+// delete. The response is written without a body.
+func NoContent() (resp ApiResponse, status int) {
+	resp, _ = Success(make(map[string]string, 0), "")
+	return resp, http.StatusNoContent
+}
+
+// SuccessWithMeta is Success with metadata about data, such as the cursors
+// of a list.
+func SuccessWithMeta(data interface{}, meta interface{}, message string) (resp ApiResponse, status int) {
+	resp, status = Success(data, message)
+	resp.Meta = meta
+	return resp, status
+}
+
 func Error(message string, statusCode int, data interface{}) (resp ApiResponse, status int) {
 	if data == nil {
 		data = make(map[string]string, 0)